@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
 	"log"
 
+	"sewascaf.com/api/internal/admin"
 	"sewascaf.com/api/internal/auth"
 	"sewascaf.com/api/internal/bookmark"
+	"sewascaf.com/api/internal/cart"
 	"sewascaf.com/api/internal/chatbot"
 	"sewascaf.com/api/internal/config"
 	"sewascaf.com/api/internal/database"
+	"sewascaf.com/api/internal/geo"
+	"sewascaf.com/api/internal/inspection"
+	"sewascaf.com/api/internal/mailer"
 	"sewascaf.com/api/internal/middleware"
 	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/notification"
 	"sewascaf.com/api/internal/order"
 	"sewascaf.com/api/internal/product"
+	"sewascaf.com/api/internal/ratelimit"
+	"sewascaf.com/api/internal/refund"
+	"sewascaf.com/api/internal/scheduler"
+	"sewascaf.com/api/internal/session"
 	"sewascaf.com/api/internal/shop"
 	"sewascaf.com/api/internal/tripay"
 	"sewascaf.com/api/internal/user"
@@ -28,68 +39,196 @@ func main() {
 
 	db := database.InitDB(cfg.DatabaseURL)
 
-	// runMigrations(db)
+	runMigrations(db)
+	if err := database.MigrateData(db); err != nil {
+		log.Fatalf("Failed to migrate data: %v", err)
+	}
+
+	refundProvider, err := refund.NewProvider(cfg.RefundProvider)
+	if err != nil {
+		log.Fatalf("Could not create refund provider: %v", err)
+	}
+
+	orderScheduler := scheduler.New(db, scheduler.RealClock{}, cfg.PendingOrderTTL, refundProvider)
+	go orderScheduler.Run(context.Background(), cfg.SchedulerInterval)
 
 	router := gin.Default()
 
-	authHandler := auth.NewHandler(db, cfg.JWTSecret)
-	userHandler := user.NewHandler(db, cfg.SupabaseURL, cfg.SupabaseServiceKey, cfg.JWTSecret)
+	tripayClient := newTripayClient(cfg)
+	geocoder, err := geo.NewGeocoder(cfg.Geocoder)
+	if err != nil {
+		log.Fatalf("Could not create geocoder: %v", err)
+	}
+	mail, err := mailer.New(cfg.Mailer, mailer.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.MailFrom,
+	}, cfg.MailDir)
+	if err != nil {
+		log.Fatalf("Could not create mailer: %v", err)
+	}
+	rateLimits, err := ratelimit.NewStore(cfg.RateLimitStore)
+	if err != nil {
+		log.Fatalf("Could not create rate limit store: %v", err)
+	}
+
+	sessions := session.NewIssuer(db, cfg.JWTSecret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+
+	authHandler := auth.NewHandler(db, sessions, geocoder, mail, cfg.AppBaseURL, auth.LockoutPolicy{
+		BackoffAfter:    cfg.LoginBackoffAfter,
+		MaxFailures:     cfg.LoginMaxFailures,
+		BackoffBase:     cfg.LoginBackoffBase,
+		LockoutDuration: cfg.LoginLockoutPeriod,
+		IPMaxFailures:   cfg.LoginIPMaxFailures,
+		IPWindow:        cfg.LoginIPWindow,
+	})
+	userHandler := user.NewHandler(db, cfg.SupabaseURL, cfg.SupabaseServiceKey, sessions, geocoder)
 	productHandler := product.NewHandler(db, cfg.SupabaseURL, cfg.SupabaseServiceKey)
-	tripayHandler := tripay.NewHandler(db, cfg.TripayAPIKey, cfg.TripayPrivateKey)
-	shopHandler := shop.NewHandler(db)
+	tripayHandler := tripay.NewHandler(db, tripayClient, cfg.TripayPrivateKey)
+	shopHandler := shop.NewHandler(db, tripayClient, refundProvider, geocoder)
 	bookmarkHandler := bookmark.NewHandler(db)
-	orderHandler := order.NewHandler(db, cfg.TripayAPIKey, cfg.TripayPrivateKey, cfg.TripayMerchantCode)
+	orderHandler := order.NewHandler(db, tripayClient, refundProvider, cfg.PendingOrderTTL)
 	chatbotHandler := chatbot.NewHandler(db, cfg.GeminiAPIKey)
+	cartHandler := cart.NewHandler(db, tripayClient, cfg.CheckoutPaymentMode)
+	notificationHandler := notification.NewHandler(db)
+	inspectionHandler := inspection.NewHandler(db, cfg.SupabaseURL, cfg.SupabaseServiceKey)
+	adminHandler := admin.NewHandler(db)
+
+	// Fitur vendor tertutup untuk vendor tanpa 2FA jika admin mewajibkannya
+	requireTwoFactor := middleware.RequireTwoFactor(db)
 
 	v1 := router.Group("/api/v1")
 	{
 		// AI BOT
-		v1.POST("/chatbot/ask", middleware.AuthMiddleware(cfg.JWTSecret), chatbotHandler.AskChatbot)
+		v1.POST("/chatbot/ask", middleware.AuthMiddleware(sessions), middleware.RateLimit(rateLimits, "chatbot", cfg.RateLimitChatbot, cfg.RateLimitWindow), chatbotHandler.AskChatbot)
 		// User
 		v1.GET("/products", productHandler.GetProducts)
 		v1.GET("/products/:productId", productHandler.GetProductDetail)
+		v1.GET("/products/:productId/quote", productHandler.GetProductQuote)
+		v1.GET("/products/:productId/availability", productHandler.GetProductAvailability)
+		v1.GET("/products/:productId/seasonal-prices", productHandler.GetSeasonalPrices)
+
+		v1.POST("/products/:productId/bookmarks", middleware.AuthMiddleware(sessions), bookmarkHandler.AddBookmark)
+		v1.DELETE("/products/:productId/bookmarks", middleware.AuthMiddleware(sessions), bookmarkHandler.DeleteBookmark)
+		v1.GET("/users/me/bookmarks", middleware.AuthMiddleware(sessions), bookmarkHandler.GetUserBookmarks)
+
+		v1.GET("/users/me/notifications", middleware.AuthMiddleware(sessions), notificationHandler.GetNotifications)
+		v1.POST("/users/me/notifications/:notificationId/read", middleware.AuthMiddleware(sessions), notificationHandler.MarkNotificationRead)
 
-		v1.POST("/products/:productId/bookmarks", middleware.AuthMiddleware(cfg.JWTSecret), bookmarkHandler.AddBookmark)
-		v1.DELETE("/products/:productId/bookmarks", middleware.AuthMiddleware(cfg.JWTSecret), bookmarkHandler.DeleteBookmark)
-		v1.GET("/users/me/bookmarks", middleware.AuthMiddleware(cfg.JWTSecret), bookmarkHandler.GetUserBookmarks)
+		v1.GET("/users/me/cart", middleware.AuthMiddleware(sessions), cartHandler.GetCart)
+		v1.POST("/users/me/cart", middleware.AuthMiddleware(sessions), cartHandler.AddToCart)
+		v1.PUT("/users/me/cart/:itemId", middleware.AuthMiddleware(sessions), cartHandler.UpdateCartItem)
+		v1.DELETE("/users/me/cart/:itemId", middleware.AuthMiddleware(sessions), cartHandler.RemoveCartItem)
+		v1.POST("/users/me/cart/checkout", middleware.AuthMiddleware(sessions), cartHandler.Checkout)
 
-		v1.POST("/orders", middleware.AuthMiddleware(cfg.JWTSecret), orderHandler.CreateOrder)
+		v1.POST("/orders", middleware.AuthMiddleware(sessions), orderHandler.CreateOrder)
+		v1.POST("/orders/quote", middleware.AuthMiddleware(sessions), orderHandler.QuoteOrder)
 		v1.POST("/tripay/callback", tripayHandler.CallbackHandler)
-		v1.GET("/users/me/orders", middleware.AuthMiddleware(cfg.JWTSecret), orderHandler.GetUserOrders)
-		v1.POST("/orders/:orderId/cancel", middleware.AuthMiddleware(cfg.JWTSecret), orderHandler.CancelOrder)
+		v1.GET("/users/me/orders", middleware.AuthMiddleware(sessions), orderHandler.GetUserOrders)
+		v1.GET("/orders/:orderId", middleware.AuthMiddleware(sessions), orderHandler.GetOrderDetail)
+		v1.POST("/orders/:orderId/cancel", middleware.AuthMiddleware(sessions), orderHandler.CancelOrder)
+		v1.GET("/orders/:orderId/cancellation-quote", middleware.AuthMiddleware(sessions), orderHandler.GetCancellationQuote)
+		v1.POST("/orders/:orderId/extensions", middleware.AuthMiddleware(sessions), orderHandler.CreateExtension)
+		v1.GET("/orders/:orderId/extensions", middleware.AuthMiddleware(sessions), orderHandler.GetExtensions)
+		v1.GET("/orders/:orderId/deposit", middleware.AuthMiddleware(sessions), orderHandler.GetOrderDeposit)
 
 		// Auth
-		v1.POST("/register", authHandler.Register)
-		v1.POST("/login", authHandler.Login)
-		v1.GET("/users/profile", middleware.AuthMiddleware(cfg.JWTSecret), userHandler.GetProfile)
-		v1.POST("/users/upgrade-to-vendor", middleware.AuthMiddleware(cfg.JWTSecret), userHandler.UpgradeToVendor)
+		v1.POST("/register", middleware.RateLimit(rateLimits, "register", cfg.RateLimitAuth, cfg.RateLimitWindow), authHandler.Register)
+		v1.POST("/login", middleware.RateLimit(rateLimits, "login", cfg.RateLimitAuth, cfg.RateLimitWindow), authHandler.Login)
+		v1.POST("/auth/login/2fa", middleware.RateLimit(rateLimits, "login", cfg.RateLimitAuth, cfg.RateLimitWindow), authHandler.LoginTwoFactor)
+		v1.POST("/auth/refresh", authHandler.Refresh)
+		v1.POST("/auth/logout", middleware.AuthMiddleware(sessions), authHandler.Logout)
+		v1.POST("/auth/logout-all", middleware.AuthMiddleware(sessions), authHandler.LogoutAll)
+		v1.GET("/auth/sessions", middleware.AuthMiddleware(sessions), authHandler.GetSessions)
+		v1.DELETE("/auth/sessions/:sessionId", middleware.AuthMiddleware(sessions), authHandler.RevokeSession)
+		v1.POST("/auth/verify-email", authHandler.VerifyEmail)
+		v1.POST("/auth/resend-verification", middleware.AuthMiddleware(sessions), authHandler.ResendVerification)
+		v1.POST("/auth/forgot-password", middleware.RateLimit(rateLimits, "forgot-password", cfg.RateLimitAuth, cfg.RateLimitWindow), authHandler.ForgotPassword)
+		v1.POST("/auth/reset-password", authHandler.ResetPassword)
+		v1.GET("/auth/login-attempts", middleware.AuthMiddleware(sessions), authHandler.GetLoginAttempts)
+		v1.GET("/users/profile", middleware.AuthMiddleware(sessions), userHandler.GetProfile)
+		v1.PUT("/users/me/address", middleware.AuthMiddleware(sessions), userHandler.UpdateAddress)
+		v1.POST("/users/upgrade-to-vendor", middleware.AuthMiddleware(sessions), userHandler.UpgradeToVendor)
+		v1.GET("/users/me/2fa", middleware.AuthMiddleware(sessions), userHandler.GetTwoFactorStatus)
+		v1.POST("/users/me/2fa/setup", middleware.AuthMiddleware(sessions), userHandler.SetupTwoFactor)
+		v1.POST("/users/me/2fa/enable", middleware.AuthMiddleware(sessions), userHandler.EnableTwoFactor)
+		v1.POST("/users/me/2fa/disable", middleware.AuthMiddleware(sessions), userHandler.DisableTwoFactor)
+		v1.POST("/users/me/2fa/recovery-codes", middleware.AuthMiddleware(sessions), userHandler.RegenerateRecoveryCodes)
+
+		// Admin
+		v1.GET("/admin/security", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RoleAdmin), adminHandler.GetSecuritySettings)
+		v1.PUT("/admin/security", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RoleAdmin), adminHandler.UpdateSecuritySettings)
 
 		// Vendor
 
-		v1.GET("/shops/me", middleware.AuthMiddleware(cfg.JWTSecret), shopHandler.GetShopProfile)
-		v1.PUT("/shops/me", middleware.AuthMiddleware(cfg.JWTSecret), shopHandler.UpdateShopProfile)
-		v1.GET("/shops/me/orders", middleware.AuthMiddleware(cfg.JWTSecret), shopHandler.GetShopOrders)
-		v1.PUT("/orders/:orderId/status", middleware.AuthMiddleware(cfg.JWTSecret), shopHandler.UpdateOrderStatus)
+		v1.GET("/shops/me", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.GetShopProfile)
+		v1.PUT("/shops/me", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.UpdateShopProfile)
+		v1.GET("/shops/me/orders", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.GetShopOrders)
+		v1.PUT("/orders/:orderId/status", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.UpdateOrderStatus)
+		v1.POST("/orders/:orderId/approve", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.ApproveOrder)
+		v1.POST("/orders/:orderId/reject", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.RejectOrder)
+		v1.PUT("/shops/me/approval", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.UpdateApprovalSettings)
+		v1.POST("/orders/:orderId/deposit/settle", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.SettleDeposit)
+		v1.POST("/orders/:orderId/late-fee/payment", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.IssueLateFeePayment)
+		v1.POST("/orders/:orderId/inspection", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, inspectionHandler.CreateInspection)
+		v1.GET("/orders/:orderId/inspection", middleware.AuthMiddleware(sessions), inspectionHandler.GetInspection)
+		v1.POST("/orders/:orderId/damage-claims", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, inspectionHandler.CreateDamageClaim)
+		v1.POST("/orders/:orderId/damage-claims/:claimId/accept", middleware.AuthMiddleware(sessions), inspectionHandler.AcceptDamageClaim)
+		v1.POST("/orders/:orderId/damage-claims/:claimId/dispute", middleware.AuthMiddleware(sessions), inspectionHandler.DisputeDamageClaim)
+		v1.POST("/orders/:orderId/damage-claims/:claimId/withdraw", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, inspectionHandler.WithdrawDamageClaim)
 		
-		v1.POST("/products", middleware.AuthMiddleware(cfg.JWTSecret), productHandler.CreateProduct)
-		v1.GET("/products/my-shop", middleware.AuthMiddleware(cfg.JWTSecret), productHandler.GetShopProducts)
-		v1.PUT("/products/:productId", middleware.AuthMiddleware(cfg.JWTSecret), productHandler.UpdateProduct)
-		v1.DELETE("/products/:productId", middleware.AuthMiddleware(cfg.JWTSecret), productHandler.DeleteProduct)
+		v1.POST("/products", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, productHandler.CreateProduct)
+		v1.GET("/products/my-shop", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, productHandler.GetShopProducts)
+		v1.PUT("/products/:productId", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, productHandler.UpdateProduct)
+		v1.DELETE("/products/:productId", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, productHandler.DeleteProduct)
+		v1.POST("/products/:productId/seasonal-prices", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, productHandler.CreateSeasonalPrice)
+		v1.DELETE("/products/:productId/seasonal-prices/:seasonId", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, productHandler.DeleteSeasonalPrice)
+		v1.GET("/products/:productId/maintenance", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, productHandler.GetMaintenanceBlocks)
+		v1.POST("/products/:productId/maintenance", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, productHandler.CreateMaintenanceBlock)
+		v1.DELETE("/products/:productId/maintenance/:blockId", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, productHandler.DeleteMaintenanceBlock)
+
+		v1.GET("/shops/me/statistics", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.GetShopStatistics)
+		v1.POST("/products/:productId/reviews", middleware.AuthMiddleware(sessions), productHandler.CreateReview)
+
+		v1.GET("/payment-channels", middleware.AuthMiddleware(sessions), tripayHandler.GetPaymentChannels)
+		v1.PUT("/shops/me/payment-channels", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.UpdatePaymentChannels)
+		v1.GET("/shops/me/payment-channels", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.GetShopPaymentChannels)
+		v1.GET("/shops/:shopId/payment-channels", shopHandler.GetPublicPaymentChannels)
+		v1.GET("/shops/:shopId/cancellation-policy", shopHandler.GetCancellationPolicy)
+		v1.PUT("/shops/me/cancellation-policy", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.UpdateCancellationPolicy)
+		v1.GET("/shops/:shopId/delivery", shopHandler.GetDeliverySettings)
+		v1.GET("/shops/:shopId/closures", shopHandler.GetShopClosures)
+		v1.POST("/shops/me/closures", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.CreateShopClosure)
+		v1.DELETE("/shops/me/closures/:closureId", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.DeleteShopClosure)
+		v1.PUT("/shops/me/delivery", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.UpdateDeliverySettings)
+		v1.POST("/shops/me/delivery-zones", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.CreateDeliveryZone)
+		v1.DELETE("/shops/me/delivery-zones/:zoneId", middleware.AuthMiddleware(sessions), middleware.RequireRole(models.RolePengusaha), requireTwoFactor, shopHandler.DeleteDeliveryZone)
+	}
 
-		v1.GET("/shops/me/statistics", middleware.AuthMiddleware(cfg.JWTSecret), shopHandler.GetShopStatistics)
-		v1.POST("/products/:productId/reviews", middleware.AuthMiddleware(cfg.JWTSecret), productHandler.CreateReview)
+	router.Run(":8080")
+}
 
-		v1.GET("/payment-channels", middleware.AuthMiddleware(cfg.JWTSecret), tripayHandler.GetPaymentChannels)
-		v1.PUT("/shops/me/payment-channels", middleware.AuthMiddleware(cfg.JWTSecret), shopHandler.UpdatePaymentChannels)
-		v1.GET("/shops/me/payment-channels", middleware.AuthMiddleware(cfg.JWTSecret), shopHandler.GetShopPaymentChannels)
+// newTripayClient membuat client Tripay sesuai TRIPAY_MODE.
+// Mode "fake" menjalankan server Tripay tiruan di dalam proses untuk development lokal.
+func newTripayClient(cfg *config.Config) tripay.Client {
+	if cfg.TripayMode == "fake" {
+		fake := tripay.NewFakeServer(cfg.TripayAPIKey, cfg.TripayPrivateKey, cfg.TripayMerchantCode)
+		log.Printf("Using fake Tripay server at %s", fake.URL)
+		return fake.Client()
 	}
 
-	router.Run(":8080")
+	baseURL := cfg.TripayBaseURL
+	if baseURL == "" {
+		baseURL = tripay.BaseURLForMode(cfg.TripayMode)
+	}
+	return tripay.NewClient(baseURL, cfg.TripayAPIKey, cfg.TripayPrivateKey, cfg.TripayMerchantCode)
 }
 
 func runMigrations(db *gorm.DB) {
 	log.Println("Running database migrations...")
-	err := db.AutoMigrate(&models.User{}, &models.Shop{}, &models.Product{}, &models.Order{}, &models.Review{}, &models.OrderItem{}, &models.Bookmark{}, &models.ChatHistory{})
+	err := database.AutoMigrate(db)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -0,0 +1,92 @@
+// Lokasi: internal/admin/handler.go
+package admin
+
+import (
+	"net/http"
+
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/notification"
+	"sewascaf.com/api/internal/twofactor"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Handler struct {
+	DB *gorm.DB
+}
+
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{DB: db}
+}
+
+type SecuritySettingsPayload struct {
+	RequireVendorTwoFactor *bool `json:"require_vendor_two_factor" binding:"required"`
+}
+
+// GetSecuritySettings menampilkan pengaturan keamanan platform
+func (h *Handler) GetSecuritySettings(c *gin.Context) {
+	settings, err := twofactor.LoadSettings(h.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load security settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSecuritySettings mengubah pengaturan keamanan platform. Saat 2FA mulai
+// diwajibkan, vendor yang belum mengaktifkannya diberi notifikasi; fitur vendor
+// mereka tertutup sampai enrollment selesai.
+func (h *Handler) UpdateSecuritySettings(c *gin.Context) {
+	var payload SecuritySettingsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	adminID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+		return
+	}
+
+	var settings models.SecuritySettings
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		current, err := twofactor.LoadSettings(tx)
+		if err != nil {
+			return err
+		}
+		settings = current
+		settings.RequireVendorTwoFactor = *payload.RequireVendorTwoFactor
+		settings.UpdatedByID = &adminID
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&settings).Error; err != nil {
+			return err
+		}
+		if current.RequireVendorTwoFactor || !settings.RequireVendorTwoFactor {
+			return nil
+		}
+
+		var vendorIDs []uuid.UUID
+		err = tx.Model(&models.User{}).
+			Where("role = ? AND two_factor_enabled_at IS NULL", models.RolePengusaha).
+			Pluck("id", &vendorIDs).Error
+		if err != nil {
+			return err
+		}
+		for _, vendorID := range vendorIDs {
+			err := notification.Notify(tx, vendorID, notification.TypeTwoFactorRequired,
+				"Two-factor authentication required",
+				"Enable two-factor authentication in your account settings to keep managing your shop.", nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
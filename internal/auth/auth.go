@@ -7,10 +7,13 @@ import (
 	"net/http"
 	"time"
 
+	"sewascaf.com/api/internal/geo"
+	"sewascaf.com/api/internal/mailer"
 	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/session"
+	"sewascaf.com/api/internal/twofactor"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm" // <-- IMPORT BARU
@@ -19,14 +22,22 @@ import (
 // Handler adalah struct yang akan menampung dependensi seperti koneksi DB
 type Handler struct {
 	DB *gorm.DB
-	JWTSecret string
+	Sessions   *session.Issuer
+	Geocoder   geo.Geocoder
+	Mailer     mailer.Mailer
+	AppBaseURL string // Untuk link di email verifikasi dan reset password
+	Lockout    LockoutPolicy
 }
 
 // NewHandler adalah "constructor" untuk membuat instance Handler baru
-func NewHandler(db *gorm.DB, jwtSecret string) *Handler {
+func NewHandler(db *gorm.DB, sessions *session.Issuer, geocoder geo.Geocoder, mail mailer.Mailer, appBaseURL string, lockout LockoutPolicy) *Handler {
 	return &Handler{
-		DB:        db,
-		JWTSecret: jwtSecret,
+		DB:         db,
+		Sessions:   sessions,
+		Geocoder:   geocoder,
+		Mailer:     mail,
+		AppBaseURL: appBaseURL,
+		Lockout:    lockout,
 	}
 }
 
@@ -45,39 +56,105 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	// 2. Cari user di database berdasarkan email
+	// 2. Tahan IP yang terlalu sering gagal login, apa pun email yang dicoba
+	now := time.Now()
+	ipRetryAt, err := h.ipRetryAt(c.ClientIP(), now)
+	if err != nil {
+		log.Printf("Failed to check login attempts from %s: %v", c.ClientIP(), err)
+	}
+	if now.Before(ipRetryAt) {
+		h.recordAttempt(c, payload.Email, nil, AttemptIPThrottled)
+		respondTooManyAttempts(c, ipRetryAt, "Too many failed login attempts from this address, please try again later")
+		return
+	}
+
+	// 3. Cari user di database berdasarkan email. Email yang tidak terdaftar tetap
+	// menjalankan bcrypt agar waktu respons tidak membocorkan email mana yang terdaftar.
 	if result := h.DB.Where("email = ?", payload.Email).First(&user); result.Error != nil {
+		compareDummyPassword(payload.Password)
+		h.recordAttempt(c, payload.Email, nil, AttemptUnknownEmail)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	// Akun yang sedang tertahan dijawab persis seperti password salah; status
+	// penguncian hanya dicatat di server. Password tetap dicek demi waktu respons
+	// yang sama, tetapi hasilnya diabaikan.
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.Password))
+		h.recordAttempt(c, payload.Email, &user.ID, AttemptAccountLocked)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	// 3. Bandingkan password dari request dengan hash di database
+	// 4. Bandingkan password dari request dengan hash di database
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.Password)); err != nil {
+		h.recordAttempt(c, payload.Email, &user.ID, AttemptInvalidPassword)
+		if err := h.recordFailure(user.ID, now); err != nil {
+			log.Printf("Failed to record failed login for user %s: %v", user.ID, err)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	// 4. Jika password cocok, buat JWT Token
-	// Tentukan 'claims' atau data yang akan dimasukkan ke dalam token
-	claims := jwt.MapClaims{
-		"sub": user.ID,                              // Subject (identitas user)
-		"exp": time.Now().Add(time.Hour * 24).Unix(), // Waktu kedaluwarsa (24 jam)
+	// 5. User dengan 2FA harus menyelesaikan langkah kedua lewat /auth/login/2fa
+	if twofactor.Enabled(user) {
+		h.recordAttempt(c, payload.Email, &user.ID, AttemptTwoFactorPending)
+		challenge, err := h.Sessions.Challenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":             "Two-factor authentication required",
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+			"expires_in":          int(session.ChallengeTTL.Seconds()),
+		})
+		return
 	}
 
-	// Buat token dengan claims dan metode signing HS256
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	h.completeLogin(c, user, false)
+}
 
-	// Tandatangani token dengan secret key kita
-	tokenString, err := token.SignedString([]byte(h.JWTSecret))
+// completeLogin mencatat login berhasil, mereset hitungan gagal, lalu membuat
+// sesi baru dan mengirim access token berumur pendek beserta refresh token.
+// twoFactor menandai login yang sudah melewati langkah kedua 2FA.
+func (h *Handler) completeLogin(c *gin.Context, user models.User, twoFactor bool) {
+	h.recordAttempt(c, user.Email, &user.ID, AttemptSucceeded)
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		err := h.DB.Model(&user).Updates(map[string]interface{}{"failed_login_attempts": 0, "locked_until": nil}).Error
+		if err != nil {
+			log.Printf("Failed to reset failed logins for user %s: %v", user.ID, err)
+		}
+	}
+
+	tokens, err := h.Sessions.Start(user, c.Request.UserAgent(), c.ClientIP(), twoFactor)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// 5. Kirim token sebagai response
+	// Vendor tanpa 2FA tetap bisa login untuk melakukan enrollment jika admin mewajibkannya
+	setupRequired := false
+	if !twofactor.Enabled(user) {
+		required, err := twofactor.Required(h.DB, user.Role)
+		if err != nil {
+			log.Printf("Failed to load two-factor policy: %v", err)
+		}
+		setupRequired = required
+	}
+
+	// "token" tetap dikirim untuk client lama
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   tokenString,
+		"message":                   "Login successful",
+		"token":                     tokens.AccessToken,
+		"access_token":              tokens.AccessToken,
+		"token_type":                tokens.TokenType,
+		"expires_in":                tokens.ExpiresIn,
+		"refresh_token":             tokens.RefreshToken,
+		"refresh_expires_at":        tokens.RefreshExpiresAt,
+		"two_factor_setup_required": setupRequired,
 	})
 }
 
@@ -88,7 +165,9 @@ type RegisterPayload struct {
 	Pekerjaan string `json:"pekerjaan" binding:"required"`
 	Alamat   string `json:"alamat" binding:"required"`
 	Telepon  string `json:"telepon" binding:"required"`
-	Role     string `json:"role" binding:"required"`
+	Role     string `json:"role" binding:"omitempty,eq=user"` // Hanya "user"; pengusaha lewat upgrade-to-vendor
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
 }
 
 // Register sekarang adalah method dari struct Handler
@@ -100,6 +179,13 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
+	// Koordinat alamat dipakai untuk mencari toko terdekat dan menghitung ongkos kirim
+	location, err := geo.Resolve(c.Request.Context(), h.Geocoder, payload.Alamat, payload.Latitude, payload.Longitude)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
@@ -114,7 +200,10 @@ func (h *Handler) Register(c *gin.Context) {
 		Pekerjaan: payload.Pekerjaan,
 		Alamat:   payload.Alamat,
 		Telepon:  payload.Telepon,
-		Role:     payload.Role,
+		Role:     models.RoleUser,
+	}
+	if location != nil {
+		newUser.Latitude, newUser.Longitude = &location.Lat, &location.Lng
 	}
 
 	if result := h.DB.Create(&newUser); result.Error != nil {
@@ -126,6 +215,11 @@ func (h *Handler) Register(c *gin.Context) {
     return
 }
 
+	// Email verifikasi tidak menggagalkan registrasi; user bisa meminta kirim ulang
+	if err := h.sendVerificationEmail(c.Request.Context(), newUser); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", newUser.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "User registered successfully",
 		"user": gin.H{
@@ -0,0 +1,155 @@
+// Lokasi: internal/auth/lockout.go
+package auth
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Alasan percobaan login yang dicatat di LoginAttempt
+const (
+	AttemptSucceeded        = "succeeded"
+	AttemptInvalidPassword  = "invalid_password"
+	AttemptUnknownEmail     = "unknown_email"
+	AttemptAccountLocked    = "account_locked"
+	AttemptIPThrottled      = "ip_throttled"
+	AttemptTwoFactorPending = "two_factor_pending"
+	AttemptInvalidTwoFactor = "invalid_two_factor_code"
+	AttemptRecoveryCodeUsed = "recovery_code_used"
+)
+
+// dummyPasswordHash adalah hash bcrypt (DefaultCost) dari password acak yang tidak
+// dimiliki user mana pun, dipakai untuk login dengan email yang tidak terdaftar
+const dummyPasswordHash = "$2a$10$hpnhNHmxKXFOoOZiW3a2qucgQ1y6eOxPDPojDZBQkig/UsjNGkbNG"
+
+// compareDummyPassword menghabiskan waktu yang sama dengan mengecek password user sungguhan
+func compareDummyPassword(password string) {
+	bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+}
+
+// LockoutPolicy mengatur perlindungan brute-force login. Setelah BackoffAfter kali
+// gagal berturut-turut, jeda sebelum percobaan berikutnya naik dua kali lipat
+// mulai dari BackoffBase; setelah MaxFailures kali, akun dikunci selama
+// LockoutDuration. Hal yang sama berlaku per IP untuk percobaan gagal di IPWindow.
+// Login ke akun yang tertahan dijawab seperti password salah, jadi penyerang tidak
+// bisa membedakan akun yang terkunci dari email yang tidak terdaftar.
+type LockoutPolicy struct {
+	BackoffAfter    int
+	MaxFailures     int
+	BackoffBase     time.Duration
+	LockoutDuration time.Duration
+	IPMaxFailures   int
+	IPWindow        time.Duration
+}
+
+// backoff adalah jeda setelah excess kali gagal melewati batas gratis, dibatasi LockoutDuration
+func (p LockoutPolicy) backoff(excess int) time.Duration {
+	if excess <= 0 {
+		return 0
+	}
+	d := p.BackoffBase
+	for i := 1; i < excess && d < p.LockoutDuration; i++ {
+		d *= 2
+	}
+	if d > p.LockoutDuration {
+		d = p.LockoutDuration
+	}
+	return d
+}
+
+// accountDelay adalah lama akun tertahan setelah failures kali gagal berturut-turut
+func (p LockoutPolicy) accountDelay(failures int) time.Duration {
+	if p.MaxFailures > 0 && failures >= p.MaxFailures {
+		return p.LockoutDuration
+	}
+	return p.backoff(failures - p.BackoffAfter + 1)
+}
+
+// ipRetryAt mengembalikan waktu IP boleh mencoba login lagi berdasarkan
+// percobaan gagal dari IP tersebut di IPWindow terakhir
+func (h *Handler) ipRetryAt(ip string, now time.Time) (time.Time, error) {
+	if h.Lockout.IPMaxFailures <= 0 {
+		return time.Time{}, nil
+	}
+	var stats struct {
+		Failures int
+		LastAt   *time.Time
+	}
+	err := h.DB.Model(&models.LoginAttempt{}).
+		Select("COUNT(*) AS failures, MAX(created_at) AS last_at").
+		Where("ip_address = ? AND reason IN ? AND created_at > ?", ip, []string{AttemptInvalidPassword, AttemptUnknownEmail, AttemptAccountLocked, AttemptInvalidTwoFactor}, now.Add(-h.Lockout.IPWindow)).
+		Scan(&stats).Error
+	if err != nil || stats.LastAt == nil {
+		return time.Time{}, err
+	}
+	return stats.LastAt.Add(h.Lockout.backoff(stats.Failures - h.Lockout.IPMaxFailures + 1)), nil
+}
+
+// recordFailure menambah hitungan gagal akun dan menahan akun sesuai kebijakan.
+// Baris user dikunci agar percobaan paralel tidak saling menimpa hitungan.
+func (h *Handler) recordFailure(userID uuid.UUID, now time.Time) error {
+	return h.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		failures := user.FailedLoginAttempts + 1
+		var lockedUntil *time.Time
+		if delay := h.Lockout.accountDelay(failures); delay > 0 {
+			until := now.Add(delay)
+			lockedUntil = &until
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"failed_login_attempts": failures,
+			"locked_until":          lockedUntil,
+		}).Error
+	})
+}
+
+// recordAttempt menyimpan audit percobaan login. Kegagalan mencatat tidak menggagalkan login.
+func (h *Handler) recordAttempt(c *gin.Context, email string, userID *uuid.UUID, reason string) {
+	attempt := models.LoginAttempt{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Email:     email,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Success:   reason == AttemptSucceeded,
+		Reason:    reason,
+	}
+	if err := h.DB.Create(&attempt).Error; err != nil {
+		log.Printf("Failed to record login attempt for %s: %v", email, err)
+	}
+}
+
+func respondTooManyAttempts(c *gin.Context, retryAt time.Time, message string) {
+	retryAfter := int(math.Ceil(time.Until(retryAt).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message, "retry_after": retryAfter})
+}
+
+// GetLoginAttempts menampilkan riwayat percobaan login terbaru ke akun user yang sedang login
+func (h *Handler) GetLoginAttempts(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var attempts []models.LoginAttempt
+	if err := h.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(50).Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login attempts"})
+		return
+	}
+	c.JSON(http.StatusOK, attempts)
+}
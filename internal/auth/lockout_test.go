@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hash palsu harus memakai cost yang sama dengan password user (Register memakai
+// DefaultCost), kalau tidak waktu login email tak terdaftar tetap berbeda
+func TestDummyPasswordHashMatchesUserCost(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil {
+		t.Fatalf("dummy hash is not a bcrypt hash: %v", err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("dummy hash cost = %d, want %d", cost, bcrypt.DefaultCost)
+	}
+}
+
+func TestAccountDelay(t *testing.T) {
+	policy := LockoutPolicy{BackoffAfter: 3, MaxFailures: 6, BackoffBase: time.Second, LockoutDuration: 15 * time.Minute}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := policy.accountDelay(tt.failures); got != tt.want {
+			t.Errorf("accountDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Lokasi: internal/auth/recovery.go
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"sewascaf.com/api/internal/mailer"
+	"sewascaf.com/api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kegunaan token sekali pakai
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+var errInvalidAuthToken = errors.New("token is invalid, expired or already used")
+
+type TokenPayload struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// VerifyEmail menandai email user sudah terverifikasi memakai token dari email
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var payload TokenPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeAuthToken(tx, PurposeEmailVerification, payload.Token)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ? AND email_verified_at IS NULL", token.UserID).Update("email_verified_at", time.Now()).Error
+	})
+	if err != nil {
+		respondAuthTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification mengirim ulang email verifikasi untuk user yang sedang login
+func (h *Handler) ResendVerification(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := h.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already verified"})
+		return
+	}
+	if err := h.sendVerificationEmail(c.Request.Context(), user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword mengirim link reset password jika email terdaftar. Responsnya
+// selalu sama agar endpoint ini tidak bisa dipakai untuk menebak email terdaftar.
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var payload ForgotPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Where("email = ?", payload.Email).First(&user).Error; err == nil {
+		token, err := issueAuthToken(h.DB, user.ID, PurposePasswordReset, passwordResetTTL)
+		if err == nil {
+			err = h.Mailer.Send(c.Request.Context(), mailer.Message{
+				To:      user.Email,
+				Subject: "Reset your SewaScaf password",
+				Body: fmt.Sprintf("Hi %s,\n\nUse the link below to reset your password. The link is valid for %s and can only be used once.\n\n%s\n\nIf you did not request this, you can ignore this email.\n",
+					user.Name, passwordResetTTL, h.link("/reset-password", token)),
+			})
+		}
+		if err != nil {
+			log.Printf("Failed to send password reset email to user %s: %v", user.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword mengganti password memakai token reset. Semua sesi login user
+// dicabut sehingga perangkat lain harus login ulang.
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var payload ResetPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	var userID uuid.UUID
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeAuthToken(tx, PurposePasswordReset, payload.Token)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+		// Link reset yang berhasil dipakai juga membuktikan email milik user
+		return tx.Model(&models.User{}).Where("id = ?", token.UserID).Updates(map[string]interface{}{
+			"password":              string(hashedPassword),
+			"email_verified_at":     gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+			"failed_login_attempts": 0,
+			"locked_until":          nil,
+		}).Error
+	})
+	if err != nil {
+		respondAuthTokenError(c, err)
+		return
+	}
+
+	if _, err := h.Sessions.RevokeAll(userID.String()); err != nil {
+		log.Printf("Failed to revoke sessions of user %s after password reset: %v", userID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
+
+func (h *Handler) sendVerificationEmail(ctx context.Context, user models.User) error {
+	token, err := issueAuthToken(h.DB, user.ID, PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	return h.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your SewaScaf email",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. The link is valid for %s.\n\n%s\n",
+			user.Name, emailVerificationTTL, h.link("/verify-email", token)),
+	})
+}
+
+func (h *Handler) link(path, token string) string {
+	return h.AppBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// issueAuthToken membuat token sekali pakai baru dan membatalkan token lama
+// dengan kegunaan yang sama agar hanya link terakhir yang berlaku
+func issueAuthToken(db *gorm.DB, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&models.AuthToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", userID, purpose, now).
+			Update("expires_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.AuthToken{
+			ID:        uuid.New(),
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashAuthToken(token),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	return token, err
+}
+
+// consumeAuthToken mengunci token, memastikan masih berlaku, lalu menandainya terpakai
+func consumeAuthToken(tx *gorm.DB, purpose, token string) (models.AuthToken, error) {
+	var authToken models.AuthToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", hashAuthToken(token), purpose).
+		First(&authToken).Error
+	if err != nil {
+		return authToken, errInvalidAuthToken
+	}
+	now := time.Now()
+	if authToken.UsedAt != nil || now.After(authToken.ExpiresAt) {
+		return authToken, errInvalidAuthToken
+	}
+	authToken.UsedAt = &now
+	return authToken, tx.Model(&authToken).Update("used_at", now).Error
+}
+
+func respondAuthTokenError(c *gin.Context, err error) {
+	if errors.Is(err, errInvalidAuthToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process token"})
+}
+
+func hashAuthToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,123 @@
+// Lokasi: internal/auth/session.go
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/session"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RefreshPayload struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh menukar refresh token dengan access token dan refresh token baru.
+// Refresh token lama langsung tidak berlaku.
+func (h *Handler) Refresh(c *gin.Context) {
+	var payload RefreshPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tokens, err := h.Sessions.Refresh(payload.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, session.ErrInvalidToken), errors.Is(err, session.ErrSessionRevoked), errors.Is(err, session.ErrTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout mencabut sesi yang sedang dipakai
+func (h *Handler) Logout(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	sessionID, _ := c.Get("sessionID")
+	userIDString, _ := userID.(string)
+	sid, _ := sessionID.(uuid.UUID)
+
+	if _, err := h.Sessions.Revoke(userIDString, sid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll mencabut semua sesi user, termasuk sesi yang sedang dipakai
+func (h *Handler) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	userIDString, _ := userID.(string)
+
+	revoked, err := h.Sessions.RevokeAll(userIDString)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out all devices"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices", "revoked_sessions": revoked})
+}
+
+type SessionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	IPAddress string    `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used_at"`
+	Current   bool      `json:"current"`
+}
+
+// GetSessions menampilkan perangkat yang masih login dengan akun user
+func (h *Handler) GetSessions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	sessionID, _ := c.Get("sessionID")
+	userIDString, _ := userID.(string)
+	current, _ := sessionID.(uuid.UUID)
+
+	sessions, err := h.Sessions.ActiveSessions(userIDString)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		response = append(response, SessionResponse{
+			ID:        s.ID,
+			UserAgent: s.UserAgent,
+			IPAddress: s.IPAddress,
+			CreatedAt: s.CreatedAt,
+			LastUsed:  s.LastUsedAt,
+			Current:   s.ID == current,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeSession mencabut satu sesi milik user, misalnya perangkat yang hilang
+func (h *Handler) RevokeSession(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	userIDString, _ := userID.(string)
+
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	revoked, err := h.Sessions.Revoke(userIDString, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
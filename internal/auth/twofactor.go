@@ -0,0 +1,93 @@
+// Lokasi: internal/auth/twofactor.go
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/session"
+	"sewascaf.com/api/internal/twofactor"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LoginTwoFactorPayload struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // Kode TOTP atau kode cadangan
+}
+
+// LoginTwoFactor adalah langkah kedua login untuk user dengan 2FA. Kode yang
+// salah dihitung sebagai login gagal sehingga ikut kena jeda dan penguncian akun.
+func (h *Handler) LoginTwoFactor(c *gin.Context) {
+	var payload LoginTwoFactorPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	now := time.Now()
+	ipRetryAt, err := h.ipRetryAt(c.ClientIP(), now)
+	if err != nil {
+		log.Printf("Failed to check login attempts from %s: %v", c.ClientIP(), err)
+	}
+	if now.Before(ipRetryAt) {
+		respondTooManyAttempts(c, ipRetryAt, "Too many failed login attempts from this address, please try again later")
+		return
+	}
+
+	userID, jti, err := h.Sessions.ParseChallenge(payload.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token, please log in again"})
+		return
+	}
+	var user models.User
+	if err := h.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token, please log in again"})
+		return
+	}
+
+	// Sama seperti Login, akun yang sedang tertahan tidak dibedakan dari
+	// kredensial yang salah; status penguncian hanya dicatat di server
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		h.recordAttempt(c, user.Email, &user.ID, AttemptAccountLocked)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	// Challenge dihabiskan di transaksi yang sama dengan verifikasi kode, jadi kode
+	// yang salah tidak menghabiskannya tetapi login yang berhasil tidak bisa diulang
+	var usedRecovery bool
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := session.ConsumeChallenge(tx, user.ID, jti); err != nil {
+			return err
+		}
+		var err error
+		usedRecovery, err = twofactor.Verify(tx, user.ID, payload.Code, now)
+		return err
+	})
+	if errors.Is(err, session.ErrInvalidToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token, please log in again"})
+		return
+	}
+	if errors.Is(err, twofactor.ErrInvalidCode) || errors.Is(err, twofactor.ErrNotEnabled) {
+		h.recordAttempt(c, user.Email, &user.ID, AttemptInvalidTwoFactor)
+		if err := h.recordFailure(user.ID, now); err != nil {
+			log.Printf("Failed to record failed login for user %s: %v", user.ID, err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+		return
+	}
+	if usedRecovery {
+		h.recordAttempt(c, user.Email, &user.ID, AttemptRecoveryCodeUsed)
+	}
+
+	h.completeLogin(c, user, true)
+}
@@ -0,0 +1,427 @@
+// Lokasi: internal/cart/handler.go
+package cart
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/order"
+	"sewascaf.com/api/internal/tripay"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Mode pembayaran checkout keranjang
+const (
+	PaymentModePerOrder = "per_order"
+	PaymentModeCombined = "combined"
+)
+
+type Handler struct {
+	DB          *gorm.DB
+	Tripay      tripay.Client
+	PaymentMode string
+}
+
+func NewHandler(db *gorm.DB, tripayClient tripay.Client, paymentMode string) *Handler {
+	return &Handler{
+		DB:          db,
+		Tripay:      tripayClient,
+		PaymentMode: paymentMode,
+	}
+}
+
+type CartItemResponse struct {
+	ID              uuid.UUID `json:"id"`
+	ProductID       uuid.UUID `json:"product_id"`
+	ProductName     string    `json:"product_name"`
+	ProductImageURL string    `json:"product_image_url"`
+	ShopID          uuid.UUID `json:"shop_id"`
+	ShopName        string    `json:"shop_name"`
+	Quantity        int       `json:"quantity"`
+	StartDate       time.Time `json:"start_date"`
+	EndDate         time.Time `json:"end_date"`
+	PricePerDay     int       `json:"price_per_day"`
+}
+
+// GetCart menampilkan isi keranjang user yang sedang login
+func (h *Handler) GetCart(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+	userIDString, ok := userIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var response []CartItemResponse
+	err := h.DB.Table("cart_items").
+		Select(`
+			cart_items.id, cart_items.product_id, cart_items.quantity, cart_items.start_date, cart_items.end_date,
+			products.name as product_name, products.image_url as product_image_url,
+			CASE WHEN products.discount_price_per_day > 0 THEN products.discount_price_per_day ELSE products.price_per_day END as price_per_day,
+			shops.id as shop_id, shops.shop_name
+		`).
+		Joins("JOIN products ON products.id = cart_items.product_id").
+		Joins("JOIN shops ON shops.id = products.shop_id").
+		Where("cart_items.user_id = ?", userIDString).
+		Order("cart_items.created_at ASC").
+		Scan(&response).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart", "details": err.Error()})
+		return
+	}
+
+	if response == nil {
+		response = make([]CartItemResponse, 0)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type CartItemPayload struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date" binding:"required"`
+}
+
+// AddToCart menambahkan produk ke keranjang. Jika produk dengan tanggal yang sama
+// sudah ada di keranjang, jumlahnya ditambahkan.
+func (h *Handler) AddToCart(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+	userIDString, ok := userIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var payload CartItemPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	startDate, endDate, err := parseDates(payload.StartDate, payload.EndDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var product models.Product
+	if err := h.DB.Select("id").Where("id = ?", payload.ProductID).First(&product).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	var item models.CartItem
+	err = h.DB.Where("user_id = ? AND product_id = ? AND start_date = ? AND end_date = ?", userIDString, product.ID, startDate, endDate).First(&item).Error
+	if err == nil {
+		if err := h.DB.Model(&item).Update("quantity", item.Quantity+payload.Quantity).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart item"})
+			return
+		}
+		c.JSON(http.StatusOK, item)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add product to cart", "details": err.Error()})
+		return
+	}
+
+	item = models.CartItem{
+		ID:        uuid.New(),
+		UserID:    uuid.MustParse(userIDString),
+		ProductID: product.ID,
+		Quantity:  payload.Quantity,
+		StartDate: startDate,
+		EndDate:   endDate,
+	}
+	if err := h.DB.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add product to cart", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+type UpdateCartItemPayload struct {
+	Quantity  int    `json:"quantity" binding:"omitempty,gt=0"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// UpdateCartItem mengubah jumlah atau tanggal sewa item keranjang
+func (h *Handler) UpdateCartItem(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+	userIDString, ok := userIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	itemID := c.Param("itemId")
+
+	var payload UpdateCartItemPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	var item models.CartItem
+	if err := h.DB.Where("id = ? AND user_id = ?", itemID, userIDString).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if payload.Quantity > 0 {
+		updates["quantity"] = payload.Quantity
+	}
+	if payload.StartDate != "" || payload.EndDate != "" {
+		startDate, endDate, err := parseDates(payload.StartDate, payload.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["start_date"] = startDate
+		updates["end_date"] = endDate
+	}
+
+	if len(updates) > 0 {
+		if err := h.DB.Model(&item).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart item"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// RemoveCartItem menghapus satu item dari keranjang
+func (h *Handler) RemoveCartItem(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+	userIDString, ok := userIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	result := h.DB.Where("id = ? AND user_id = ?", c.Param("itemId"), userIDString).Delete(&models.CartItem{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove cart item", "details": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ShopFulfillmentPayload adalah pilihan pengambilan atau pengiriman untuk order dari satu toko
+type ShopFulfillmentPayload struct {
+	ShopID string `json:"shop_id" binding:"required"`
+	order.FulfillmentPayload
+}
+
+type CheckoutPayload struct {
+	PaymentMethod string                   `json:"payment_method" binding:"required"`
+	Fulfillments  []ShopFulfillmentPayload `json:"fulfillments"`
+}
+
+// orderGroup adalah item keranjang yang akan menjadi satu order:
+// satu toko dengan periode sewa yang sama
+type orderGroup struct {
+	ShopID    uuid.UUID
+	StartDate time.Time
+	EndDate   time.Time
+	Items     []order.ItemRequest
+}
+
+// Checkout memecah keranjang menjadi satu order per toko (dan per periode sewa),
+// lalu membuat transaksi Tripay gabungan atau per order sesuai konfigurasi
+func (h *Handler) Checkout(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+	userIDString, ok := userIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	userID := uuid.MustParse(userIDString)
+
+	var payload CheckoutPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	var cartItems []models.CartItem
+	if err := h.DB.Preload("Product.Shop").Where("user_id = ?", userID).Order("created_at ASC").Find(&cartItems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart", "details": err.Error()})
+		return
+	}
+	if len(cartItems) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+		return
+	}
+
+	groups := make(map[string]*orderGroup)
+	var groupKeys []string
+	var cartItemIDs []uuid.UUID
+	for _, item := range cartItems {
+		shop := item.Product.Shop
+		if !shop.ActivePaymentChannels.Contains(payload.PaymentMethod) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Payment method " + payload.PaymentMethod + " is not accepted by shop " + shop.ShopName})
+			return
+		}
+		key := shop.ID.String() + item.StartDate.Format("2006-01-02") + item.EndDate.Format("2006-01-02")
+		group, exists := groups[key]
+		if !exists {
+			group = &orderGroup{ShopID: shop.ID, StartDate: item.StartDate, EndDate: item.EndDate}
+			groups[key] = group
+			groupKeys = append(groupKeys, key)
+		}
+		group.Items = append(group.Items, order.ItemRequest{ProductID: item.ProductID, Quantity: item.Quantity})
+		cartItemIDs = append(cartItemIDs, item.ID)
+	}
+	sort.Strings(groupKeys)
+
+	// Toko yang tidak disebut di fulfillments memakai pengambilan sendiri
+	fulfillmentByShop := make(map[uuid.UUID]order.FulfillmentPayload)
+	for _, f := range payload.Fulfillments {
+		shopID, err := uuid.Parse(f.ShopID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop_id in fulfillments"})
+			return
+		}
+		fulfillmentByShop[shopID] = f.FulfillmentPayload
+	}
+	fulfillments := make(map[string]order.Fulfillment, len(groups))
+	for _, key := range groupKeys {
+		group := groups[key]
+		f, err := order.ParseFulfillment(fulfillmentByShop[group.ShopID], group.StartDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fulfillments[key] = f
+	}
+
+	var placed []*order.Draft
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		for _, key := range groupKeys {
+			group := groups[key]
+			p, err := order.PlaceOrder(tx, userID, group.ShopID, group.StartDate, group.EndDate, payload.PaymentMethod, group.Items, fulfillments[key])
+			if err != nil {
+				return err
+			}
+			placed = append(placed, p)
+		}
+		return nil
+	})
+	if err != nil {
+		status := http.StatusConflict
+		if order.IsRequestError(err) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	h.DB.First(&user, "id = ?", userID)
+
+	checkoutID := uuid.New()
+	orderIDs := make([]uuid.UUID, 0, len(placed))
+	for _, p := range placed {
+		orderIDs = append(orderIDs, p.Order.ID)
+	}
+
+	var transactions []*tripay.Transaction
+	if h.PaymentMode == PaymentModeCombined {
+		transaction, err := order.ChargeOrders(c.Request.Context(), h.DB, h.Tripay, user, checkoutID.String(), payload.PaymentMethod, placed)
+		if err != nil {
+			respondChargeError(c, err, nil)
+			return
+		}
+		transactions = append(transactions, transaction)
+	} else {
+		for i, p := range placed {
+			transaction, err := order.ChargeOrders(c.Request.Context(), h.DB, h.Tripay, user, p.Order.ID.String(), payload.PaymentMethod, []*order.Draft{p})
+			if err != nil {
+				// Order yang belum sempat dibuatkan transaksi ikut dibatalkan
+				for _, rest := range placed[i+1:] {
+					cancelled := rest.Order
+					if err := h.DB.Transaction(func(tx *gorm.DB) error {
+						return order.CancelUnpaid(tx, &cancelled, "checkout aborted because a Tripay transaction failed")
+					}); err != nil {
+						log.Printf("Failed to cancel order %s: %v", cancelled.ID, err)
+					}
+				}
+				respondChargeError(c, err, transactions)
+				return
+			}
+			transactions = append(transactions, transaction)
+		}
+	}
+
+	if err := h.DB.Where("id IN ? AND user_id = ?", cartItemIDs, userID).Delete(&models.CartItem{}).Error; err != nil {
+		log.Printf("Failed to clear cart for user %s: %v", userID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"checkout_id":  checkoutID,
+		"order_ids":    orderIDs,
+		"transactions": transactions,
+	})
+}
+
+// respondChargeError mengirim error pembuatan transaksi Tripay. Payment yang gagal
+// disimpan adalah error server, bukan error dari Tripay.
+func respondChargeError(c *gin.Context, err error, transactions []*tripay.Transaction) {
+	status, message := http.StatusBadGateway, "Failed to create transaction with Tripay"
+	if errors.Is(err, order.ErrPaymentNotSaved) {
+		status, message = http.StatusInternalServerError, "Failed to save payment, the order has been cancelled"
+	}
+	c.JSON(status, gin.H{
+		"error":        message,
+		"details":      err.Error(),
+		"transactions": transactions,
+	})
+}
+
+func parseDates(start, end string) (time.Time, time.Time, error) {
+	startDate, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid start_date format, use YYYY-MM-DD")
+	}
+	endDate, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid end_date format, use YYYY-MM-DD")
+	}
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, errors.New("end_date must not be before start_date")
+	}
+	return startDate, endDate, nil
+}
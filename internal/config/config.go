@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,7 +17,34 @@ type Config struct {
 	TripayAPIKey        string 
 	TripayPrivateKey    string 
 	TripayMerchantCode  string
+	TripayMode          string
+	TripayBaseURL       string
+	CheckoutPaymentMode string
+	RefundProvider      string
+	Geocoder            string
+	Mailer              string
+	SMTPHost            string
+	SMTPPort            string
+	SMTPUsername        string
+	SMTPPassword        string
+	MailFrom            string
+	MailDir             string
+	AppBaseURL          string
 	GeminiAPIKey        string
+	AccessTokenTTL      time.Duration
+	RefreshTokenTTL     time.Duration
+	PendingOrderTTL     time.Duration
+	SchedulerInterval   time.Duration
+	LoginBackoffAfter   int
+	LoginMaxFailures    int
+	LoginBackoffBase    time.Duration
+	LoginLockoutPeriod  time.Duration
+	LoginIPMaxFailures  int
+	LoginIPWindow       time.Duration
+	RateLimitStore      string
+	RateLimitAuth       int
+	RateLimitChatbot    int
+	RateLimitWindow     time.Duration
 }
 
 func LoadConfig() (*Config, error) {
@@ -54,9 +83,88 @@ func LoadConfig() (*Config, error) {
 	if tripayMerchantCode == "" {
 		log.Fatal("Error: TRIPAY_MERCHANT_CODE is not set")
 	}
+	// TRIPAY_MODE: "sandbox" (default), "production", atau "fake" untuk server tiruan lokal
+	tripayMode := os.Getenv("TRIPAY_MODE")
+	if tripayMode == "" {
+		tripayMode = "sandbox"
+	}
+	if tripayMode != "sandbox" && tripayMode != "production" && tripayMode != "fake" {
+		log.Fatalf("Error: invalid TRIPAY_MODE %q, use sandbox, production or fake", tripayMode)
+	}
+	tripayBaseURL := os.Getenv("TRIPAY_BASE_URL")
+
+	// CHECKOUT_PAYMENT_MODE: "per_order" (default, satu transaksi Tripay per toko)
+	// atau "combined" (satu transaksi Tripay untuk seluruh keranjang)
+	checkoutPaymentMode := os.Getenv("CHECKOUT_PAYMENT_MODE")
+	if checkoutPaymentMode == "" {
+		checkoutPaymentMode = "per_order"
+	}
+	if checkoutPaymentMode != "per_order" && checkoutPaymentMode != "combined" {
+		log.Fatalf("Error: invalid CHECKOUT_PAYMENT_MODE %q, use per_order or combined", checkoutPaymentMode)
+	}
+
+	// REFUND_PROVIDER: "manual" (default, refund diproses lewat dashboard Tripay)
+	// atau "fake" untuk refund yang langsung dianggap berhasil saat development
+	refundProvider := os.Getenv("REFUND_PROVIDER")
+	if refundProvider == "" {
+		refundProvider = "manual"
+	}
+	if refundProvider != "manual" && refundProvider != "fake" {
+		log.Fatalf("Error: invalid REFUND_PROVIDER %q, use manual or fake", refundProvider)
+	}
+
+	// GEOCODER: "static" (default, koordinat pusat kota dari daftar lokal)
+	geocoder := os.Getenv("GEOCODER")
+	if geocoder == "" {
+		geocoder = "static"
+	}
+	if geocoder != "static" {
+		log.Fatalf("Error: invalid GEOCODER %q, use static", geocoder)
+	}
+
+	// MAILER: "file" (default, email ditulis ke MAIL_DIR), "smtp", atau "memory"
+	mailer := os.Getenv("MAILER")
+	if mailer == "" {
+		mailer = "file"
+	}
+	if mailer != "file" && mailer != "smtp" && mailer != "memory" {
+		log.Fatalf("Error: invalid MAILER %q, use file, smtp or memory", mailer)
+	}
+	smtpHost := os.Getenv("SMTP_HOST")
+	if mailer == "smtp" && smtpHost == "" {
+		log.Fatal("Error: SMTP_HOST is not set")
+	}
+	smtpPort := envOrDefault("SMTP_PORT", "587")
+	mailFrom := envOrDefault("MAIL_FROM", "SewaScaf <no-reply@sewascaf.com>")
+	mailDir := envOrDefault("MAIL_DIR", "mail")
+	// APP_BASE_URL dipakai untuk link verifikasi email dan reset password
+	appBaseURL := envOrDefault("APP_BASE_URL", "http://localhost:3000")
+
 	geminiAPIKey := os.Getenv("GEMINI_API_KEY")
 	if geminiAPIKey == "" { log.Fatal("Error: GEMINI_API_KEY is not set") }
 
+	accessTokenTTL := durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	refreshTokenTTL := durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	pendingOrderTTL := durationFromEnv("PENDING_ORDER_TTL", 24*time.Hour)
+	schedulerInterval := durationFromEnv("SCHEDULER_INTERVAL", time.Minute)
+
+	// Perlindungan brute-force login: jeda bertingkat per akun dan per IP, lalu akun dikunci
+	loginBackoffAfter := intFromEnv("LOGIN_BACKOFF_AFTER", 3)
+	loginMaxFailures := intFromEnv("LOGIN_MAX_FAILURES", 10)
+	loginBackoffBase := durationFromEnv("LOGIN_BACKOFF_BASE", time.Second)
+	loginLockoutDuration := durationFromEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	loginIPMaxFailures := intFromEnv("LOGIN_IP_MAX_FAILURES", 20)
+	loginIPWindow := durationFromEnv("LOGIN_IP_WINDOW", 15*time.Minute)
+
+	// RATE_LIMIT_STORE: "memory" (default). Batas request per RATE_LIMIT_WINDOW, 0 berarti tanpa batas.
+	rateLimitStore := envOrDefault("RATE_LIMIT_STORE", "memory")
+	if rateLimitStore != "memory" {
+		log.Fatalf("Error: invalid RATE_LIMIT_STORE %q, use memory", rateLimitStore)
+	}
+	rateLimitAuth := intFromEnv("RATE_LIMIT_AUTH", 20)
+	rateLimitChatbot := intFromEnv("RATE_LIMIT_CHATBOT", 10)
+	rateLimitWindow := durationFromEnv("RATE_LIMIT_WINDOW", time.Minute)
+
 	return &Config{
 		DatabaseURL: dbURL,
 		JWTSecret:          jwtSecret,
@@ -65,8 +173,71 @@ func LoadConfig() (*Config, error) {
 		TripayAPIKey:       tripayAPIKey,       
 		TripayPrivateKey:   tripayPrivateKey,   
 		TripayMerchantCode: tripayMerchantCode,
+		TripayMode:         tripayMode,
+		TripayBaseURL:      tripayBaseURL,
+		CheckoutPaymentMode: checkoutPaymentMode,
+		RefundProvider:     refundProvider,
+		Geocoder:           geocoder,
+		Mailer:             mailer,
+		SMTPHost:           smtpHost,
+		SMTPPort:           smtpPort,
+		SMTPUsername:       os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:       os.Getenv("SMTP_PASSWORD"),
+		MailFrom:           mailFrom,
+		MailDir:            mailDir,
+		AppBaseURL:         appBaseURL,
 		GeminiAPIKey:       geminiAPIKey,
+		AccessTokenTTL:     accessTokenTTL,
+		RefreshTokenTTL:    refreshTokenTTL,
+		PendingOrderTTL:    pendingOrderTTL,
+		SchedulerInterval:  schedulerInterval,
+		LoginBackoffAfter:  loginBackoffAfter,
+		LoginMaxFailures:   loginMaxFailures,
+		LoginBackoffBase:   loginBackoffBase,
+		LoginLockoutPeriod: loginLockoutDuration,
+		LoginIPMaxFailures: loginIPMaxFailures,
+		LoginIPWindow:      loginIPWindow,
+		RateLimitStore:     rateLimitStore,
+		RateLimitAuth:      rateLimitAuth,
+		RateLimitChatbot:   rateLimitChatbot,
+		RateLimitWindow:    rateLimitWindow,
 	}, nil
 
 	
-}
\ No newline at end of file
+}
+
+// durationFromEnv membaca durasi opsional (contoh: "30m", "24h") dengan nilai default
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s %q, using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// intFromEnv membaca bilangan bulat opsional yang tidak negatif dengan nilai default
+func intFromEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Warning: invalid %s %q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// envOrDefault membaca variabel environment opsional dengan nilai default
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
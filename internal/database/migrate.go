@@ -0,0 +1,34 @@
+// Lokasi: internal/database/migrate.go
+package database
+
+import (
+	"sewascaf.com/api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Models adalah semua tabel yang dikelola aplikasi, dipakai oleh AutoMigrate
+// di main.go dan oleh database test
+func Models() []interface{} {
+	return []interface{}{
+		&models.User{}, &models.Shop{}, &models.Product{}, &models.Order{}, &models.Review{}, &models.OrderItem{},
+		&models.Bookmark{}, &models.ChatHistory{}, &models.Payment{}, &models.OrderStatusHistory{}, &models.PaymentEvent{},
+		&models.CartItem{}, &models.SeasonalPrice{}, &models.DepositEntry{}, &models.ReturnInspection{}, &models.InspectionItem{},
+		&models.DamageClaim{}, &models.Notification{}, &models.OrderExtension{}, &models.Refund{}, &models.DeliveryZone{},
+		&models.MaintenanceBlock{}, &models.ShopClosure{}, &models.Session{}, &models.AuthToken{}, &models.LoginAttempt{},
+		&models.RecoveryCode{}, &models.SecuritySettings{},
+	}
+}
+
+// AutoMigrate membuat atau memperbarui semua tabel di Models
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(Models()...)
+}
+
+// MigrateData menjalankan migrasi data yang wajib dilakukan di setiap start,
+// terlepas dari AutoMigrate. Setiap langkah harus aman dijalankan berulang kali.
+func MigrateData(db *gorm.DB) error {
+	// Status 'active' lama sekarang bernama 'paid'. Tanpa ini order lama tidak
+	// termasuk status yang menahan stok dan barangnya bisa disewakan dua kali.
+	return db.Model(&models.Order{}).Where("status = ?", "active").Update("status", "paid").Error
+}
@@ -0,0 +1,204 @@
+// Lokasi: internal/deposit/deposit.go
+package deposit
+
+import (
+	"errors"
+	"fmt"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Jenis catatan di buku deposit
+const (
+	EntryHold      = "hold"
+	EntryRelease   = "release"
+	EntryDeduction = "deduction"
+)
+
+var (
+	ErrNoDeposit         = errors.New("order has no deposit held")
+	ErrAlreadySettled    = errors.New("deposit for this order has already been settled")
+	ErrNotReturned       = errors.New("deposit can only be settled after the order is returned")
+	ErrInvalidDeduction  = errors.New("each deduction needs a positive amount and a reason")
+	ErrDeductionTooLarge = errors.New("total deductions exceed the deposit held")
+)
+
+// Deduction adalah potongan deposit untuk kerusakan yang terdokumentasi
+type Deduction struct {
+	Amount   int      `json:"amount"`
+	Reason   string   `json:"reason"`
+	Evidence []string `json:"evidence"`
+}
+
+// Summary adalah saldo deposit sebuah order beserta seluruh catatannya
+type Summary struct {
+	Held        int                   `json:"held"`
+	Released    int                   `json:"released"`
+	Deducted    int                   `json:"deducted"`
+	Outstanding int                   `json:"outstanding"`
+	Entries     []models.DepositEntry `json:"entries"`
+}
+
+// Ledger mengambil semua catatan deposit order dan menghitung saldonya
+func Ledger(db *gorm.DB, orderID uuid.UUID) (Summary, error) {
+	summary := Summary{Entries: make([]models.DepositEntry, 0)}
+	if err := db.Where("order_id = ?", orderID).Order("created_at ASC").Find(&summary.Entries).Error; err != nil {
+		return Summary{}, err
+	}
+	for _, entry := range summary.Entries {
+		switch entry.Type {
+		case EntryHold:
+			summary.Held += entry.Amount
+		case EntryRelease:
+			summary.Released += entry.Amount
+		case EntryDeduction:
+			summary.Deducted += entry.Amount
+		}
+	}
+	summary.Outstanding = summary.Held - summary.Released - summary.Deducted
+	return summary, nil
+}
+
+// Hold mencatat deposit order sebagai ditahan setelah pembayaran diterima.
+// Aman dipanggil berulang kali: hold hanya dicatat sekali per order.
+func Hold(tx *gorm.DB, order *models.Order) error {
+	if order.DepositAmount <= 0 {
+		return nil
+	}
+	var existing int64
+	if err := tx.Model(&models.DepositEntry{}).Where("order_id = ? AND type = ?", order.ID, EntryHold).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+	return tx.Create(&models.DepositEntry{
+		ID:      uuid.New(),
+		OrderID: order.ID,
+		Type:    EntryHold,
+		Amount:  order.DepositAmount,
+		Reason:  "deposit paid with the order",
+	}).Error
+}
+
+// Release mengembalikan seluruh sisa deposit order, misalnya saat order direfund.
+// Tidak melakukan apa pun jika tidak ada deposit yang tersisa.
+func Release(tx *gorm.DB, orderID uuid.UUID, reason string, actorID *uuid.UUID) error {
+	summary, err := Ledger(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if summary.Outstanding <= 0 {
+		return nil
+	}
+	return tx.Create(&models.DepositEntry{
+		ID:      uuid.New(),
+		OrderID: orderID,
+		Type:    EntryRelease,
+		Amount:  summary.Outstanding,
+		Reason:  reason,
+		ActorID: actorID,
+	}).Error
+}
+
+// Deduct memotong deposit order untuk satu kerusakan, paling banyak sebesar
+// sisa deposit. Mengembalikan jumlah yang benar-benar dipotong.
+func Deduct(tx *gorm.DB, orderID uuid.UUID, deduction Deduction, actorID *uuid.UUID) (int, error) {
+	if deduction.Amount <= 0 || deduction.Reason == "" {
+		return 0, ErrInvalidDeduction
+	}
+	summary, err := Ledger(tx, orderID)
+	if err != nil {
+		return 0, err
+	}
+	amount := min(deduction.Amount, summary.Outstanding)
+	if amount <= 0 {
+		return 0, nil
+	}
+	entry := models.DepositEntry{
+		ID:       uuid.New(),
+		OrderID:  orderID,
+		Type:     EntryDeduction,
+		Amount:   amount,
+		Reason:   deduction.Reason,
+		Evidence: models.JSONB(deduction.Evidence),
+		ActorID:  actorID,
+	}
+	return amount, tx.Create(&entry).Error
+}
+
+// Settle menyelesaikan deposit order yang sudah dikembalikan. Setiap potongan
+// dicatat sebagai deduction, sisanya dicatat sebagai release ke penyewa.
+// Baris order dikunci agar deposit tidak diselesaikan dua kali.
+func Settle(tx *gorm.DB, orderID uuid.UUID, deductions []Deduction, actorID *uuid.UUID) (Summary, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", orderID).Error; err != nil {
+		return Summary{}, err
+	}
+	if order.Status != lifecycle.StatusReturned && order.Status != lifecycle.StatusCompleted {
+		return Summary{}, ErrNotReturned
+	}
+	open, err := lifecycle.HasOpenClaim(tx, order.ID)
+	if err != nil {
+		return Summary{}, err
+	}
+	if open {
+		return Summary{}, lifecycle.ErrOpenClaim
+	}
+
+	summary, err := Ledger(tx, order.ID)
+	if err != nil {
+		return Summary{}, err
+	}
+	if summary.Held == 0 {
+		return Summary{}, ErrNoDeposit
+	}
+	if summary.Outstanding <= 0 {
+		return Summary{}, ErrAlreadySettled
+	}
+
+	total := 0
+	for _, deduction := range deductions {
+		if deduction.Amount <= 0 || deduction.Reason == "" {
+			return Summary{}, ErrInvalidDeduction
+		}
+		total += deduction.Amount
+	}
+	if total > summary.Outstanding {
+		return Summary{}, fmt.Errorf("%w: deductions %d, deposit %d", ErrDeductionTooLarge, total, summary.Outstanding)
+	}
+
+	for _, deduction := range deductions {
+		entry := models.DepositEntry{
+			ID:       uuid.New(),
+			OrderID:  order.ID,
+			Type:     EntryDeduction,
+			Amount:   deduction.Amount,
+			Reason:   deduction.Reason,
+			Evidence: models.JSONB(deduction.Evidence),
+			ActorID:  actorID,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return Summary{}, err
+		}
+	}
+	if remaining := summary.Outstanding - total; remaining > 0 {
+		entry := models.DepositEntry{
+			ID:      uuid.New(),
+			OrderID: order.ID,
+			Type:    EntryRelease,
+			Amount:  remaining,
+			Reason:  "deposit returned to renter",
+			ActorID: actorID,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return Summary{}, err
+		}
+	}
+	return Ledger(tx, order.ID)
+}
@@ -0,0 +1,89 @@
+package deposit
+
+import (
+	"errors"
+	"testing"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/testdb"
+
+	"gorm.io/gorm"
+)
+
+// heldOrder membuat order yang sudah dikembalikan dengan deposit 100000 yang ditahan
+func heldOrder(t *testing.T, db *gorm.DB) models.Order {
+	t.Helper()
+	order := testdb.Order(t, db, testdb.Shop(t, db), testdb.User(t, db, models.RoleUser), lifecycle.StatusReturned)
+	order.DepositAmount = 100000
+	testdb.Fatal(t, db.Model(&order).Update("deposit_amount", order.DepositAmount).Error, "set deposit")
+	testdb.Fatal(t, Hold(db, &order), "Hold")
+	return order
+}
+
+func TestSettle(t *testing.T) {
+	db := testdb.Open(t)
+	order := heldOrder(t, db)
+
+	summary, err := Settle(db, order.ID, []Deduction{{Amount: 30000, Reason: "bent pipe"}}, nil)
+	testdb.Fatal(t, err, "Settle")
+	if summary.Held != 100000 || summary.Deducted != 30000 || summary.Released != 70000 || summary.Outstanding != 0 {
+		t.Errorf("summary = %+v, want 100000 held, 30000 deducted, 70000 released", summary)
+	}
+}
+
+func TestSettleRejectsOverDeduction(t *testing.T) {
+	db := testdb.Open(t)
+	order := heldOrder(t, db)
+
+	deductions := []Deduction{
+		{Amount: 60000, Reason: "bent pipe"},
+		{Amount: 50000, Reason: "missing clamp"},
+	}
+	if _, err := Settle(db, order.ID, deductions, nil); !errors.Is(err, ErrDeductionTooLarge) {
+		t.Fatalf("Settle error = %v, want %v", err, ErrDeductionTooLarge)
+	}
+
+	summary, err := Ledger(db, order.ID)
+	testdb.Fatal(t, err, "Ledger")
+	if summary.Outstanding != 100000 || len(summary.Entries) != 1 {
+		t.Errorf("ledger = %+v, want only the hold after a rejected settle", summary)
+	}
+}
+
+func TestSettleRejectsSecondSettle(t *testing.T) {
+	db := testdb.Open(t)
+	order := heldOrder(t, db)
+
+	_, err := Settle(db, order.ID, nil, nil)
+	testdb.Fatal(t, err, "first Settle")
+	if _, err := Settle(db, order.ID, []Deduction{{Amount: 10000, Reason: "late damage report"}}, nil); !errors.Is(err, ErrAlreadySettled) {
+		t.Fatalf("second Settle error = %v, want %v", err, ErrAlreadySettled)
+	}
+
+	summary, err := Ledger(db, order.ID)
+	testdb.Fatal(t, err, "Ledger")
+	if summary.Released != 100000 || summary.Deducted != 0 {
+		t.Errorf("summary = %+v, want the full deposit released once", summary)
+	}
+}
+
+func TestSettleRejectsInvalidDeduction(t *testing.T) {
+	db := testdb.Open(t)
+	order := heldOrder(t, db)
+
+	for _, deduction := range []Deduction{{Amount: 0, Reason: "nothing"}, {Amount: 10000}} {
+		if _, err := Settle(db, order.ID, []Deduction{deduction}, nil); !errors.Is(err, ErrInvalidDeduction) {
+			t.Errorf("Settle(%+v) error = %v, want %v", deduction, err, ErrInvalidDeduction)
+		}
+	}
+}
+
+func TestSettleRequiresReturnedOrder(t *testing.T) {
+	db := testdb.Open(t)
+	order := testdb.Order(t, db, testdb.Shop(t, db), testdb.User(t, db, models.RoleUser), lifecycle.StatusPickedUp)
+
+	if _, err := Settle(db, order.ID, nil, nil); !errors.Is(err, ErrNotReturned) {
+		t.Errorf("Settle error = %v, want %v", err, ErrNotReturned)
+	}
+}
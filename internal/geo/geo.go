@@ -0,0 +1,85 @@
+// Lokasi: internal/geo/geo.go
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const earthRadiusKm = 6371.0
+
+// ErrNotFound dikembalikan geocoder jika alamat tidak bisa dipetakan ke koordinat
+var ErrNotFound = errors.New("address could not be geocoded")
+
+// Point adalah koordinat lintang/bujur dalam derajat
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Valid mengecek apakah koordinat berada di rentang lintang/bujur yang benar
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// Geocoder mengubah alamat teks menjadi koordinat
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (Point, error)
+}
+
+// NewGeocoder membuat geocoder sesuai konfigurasi GEOCODER
+func NewGeocoder(name string) (Geocoder, error) {
+	switch name {
+	case "", "static":
+		return NewStaticGeocoder(), nil
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q", name)
+	}
+}
+
+// DistanceKm menghitung jarak lingkaran besar antara dua titik dengan rumus haversine
+func DistanceKm(a, b Point) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// DistanceSQL adalah rumus haversine dalam SQL Postgres untuk kolom latCol/lngCol.
+// Parameternya berurutan: lat, lat, lng titik asal.
+func DistanceSQL(latCol, lngCol string) string {
+	return fmt.Sprintf("(%g * 2 * ASIN(SQRT(POWER(SIN(RADIANS(%s - ?) / 2), 2) + COS(RADIANS(?)) * COS(RADIANS(%s)) * POWER(SIN(RADIANS(%s - ?) / 2), 2))))",
+		earthRadiusKm, latCol, latCol, lngCol)
+}
+
+// Resolve menentukan koordinat sebuah alamat: koordinat yang dikirim user dipakai
+// jika lengkap, selain itu alamat di-geocode. Mengembalikan nil tanpa error jika
+// alamat tidak dikenal geocoder.
+func Resolve(ctx context.Context, g Geocoder, address string, lat, lng *float64) (*Point, error) {
+	if lat != nil || lng != nil {
+		if lat == nil || lng == nil {
+			return nil, errors.New("latitude and longitude must be provided together")
+		}
+		p := Point{Lat: *lat, Lng: *lng}
+		if !p.Valid() {
+			return nil, errors.New("latitude or longitude is out of range")
+		}
+		return &p, nil
+	}
+	if address == "" {
+		return nil, nil
+	}
+	p, err := g.Geocode(ctx, address)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
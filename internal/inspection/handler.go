@@ -0,0 +1,466 @@
+// Lokasi: internal/inspection/handler.go
+package inspection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/deposit"
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/middleware"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kondisi barang saat dikembalikan
+const (
+	ConditionGood    = "good"
+	ConditionDamaged = "damaged"
+	ConditionMissing = "missing"
+)
+
+// photoBucket adalah bucket Supabase Storage untuk foto hasil pemeriksaan
+const photoBucket = "inspection-photos"
+
+var (
+	errOrderNotFound     = errors.New("order not found or you do not have permission to edit it")
+	errNotReturned       = errors.New("order must be returned before it can be inspected")
+	errAlreadyInspected  = errors.New("order has already been inspected")
+	errUnknownOrderItem  = errors.New("order item does not belong to this order")
+	errPhotoUpload       = errors.New("failed to upload inspection photo")
+	errNoInspection      = errors.New("order must be inspected before filing a damage claim")
+	errNoDamage          = errors.New("inspection found no damaged or missing items")
+	errClaimNotFound     = errors.New("damage claim not found")
+	errClaimNotOpen      = errors.New("damage claim is no longer open")
+	errClaimAlreadyFiled = errors.New("order already has an unresolved damage claim")
+)
+
+type Handler struct {
+	DB      *gorm.DB
+	Storage *storage.Supabase
+}
+
+func NewHandler(db *gorm.DB, supabaseURL string, supabaseServiceKey string) *Handler {
+	return &Handler{DB: db, Storage: storage.NewSupabase(supabaseURL, supabaseServiceKey)}
+}
+
+type InspectionItemPayload struct {
+	OrderItemID  string   `json:"order_item_id"`
+	Condition    string   `json:"condition"`
+	MissingParts []string `json:"missing_parts"`
+	Notes        string   `json:"notes"`
+}
+
+// CreateInspection mencatat kondisi setiap item order yang sudah dikembalikan.
+// Request berupa multipart form: field "items" berisi JSON daftar item, field
+// "notes" opsional, dan foto tiap item dikirim di field "photos_<order_item_id>".
+func (h *Handler) CreateInspection(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": errOrderNotFound.Error()})
+		return
+	}
+
+	var payload []InspectionItemPayload
+	if err := json.Unmarshal([]byte(c.PostForm("items")), &payload); err != nil || len(payload) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "items must be a non-empty JSON array"})
+		return
+	}
+	for _, item := range payload {
+		if item.Condition != ConditionGood && item.Condition != ConditionDamaged && item.Condition != ConditionMissing {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "condition must be one of good, damaged, missing"})
+			return
+		}
+	}
+
+	// Pemeriksaan diklaim dulu di transaksi singkat: order dikunci, dicek, dan baris
+	// inspeksi (unik per order) dibuat tanpa foto. Foto diunggah setelah commit agar
+	// lock order tidak tertahan selama upload, lalu URL-nya ditempelkan ke item.
+	var inspection models.ReturnInspection
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		order, err := findShopOrder(tx, shopID, c.Param("orderId"))
+		if err != nil {
+			return err
+		}
+		if order.Status != lifecycle.StatusReturned {
+			return errNotReturned
+		}
+		var existing int64
+		if err := tx.Model(&models.ReturnInspection{}).Where("order_id = ?", order.ID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errAlreadyInspected
+		}
+
+		var orderItems []models.OrderItem
+		if err := tx.Select("id").Where("order_id = ?", order.ID).Find(&orderItems).Error; err != nil {
+			return err
+		}
+		known := make(map[string]bool, len(orderItems))
+		for _, item := range orderItems {
+			known[item.ID.String()] = true
+		}
+		for _, item := range payload {
+			if !known[item.OrderItemID] {
+				return fmt.Errorf("%w: %s", errUnknownOrderItem, item.OrderItemID)
+			}
+		}
+
+		inspection = models.ReturnInspection{
+			ID:          uuid.New(),
+			OrderID:     order.ID,
+			InspectorID: userID,
+			Notes:       c.PostForm("notes"),
+		}
+		for _, item := range payload {
+			inspection.Items = append(inspection.Items, models.InspectionItem{
+				ID:           uuid.New(),
+				InspectionID: inspection.ID,
+				OrderItemID:  uuid.MustParse(item.OrderItemID),
+				Condition:    item.Condition,
+				MissingParts: models.JSONB(item.MissingParts),
+				Photos:       make(models.JSONB, 0),
+				Notes:        item.Notes,
+			})
+		}
+		return tx.Create(&inspection).Error
+	})
+	if err == nil {
+		err = h.attachPhotos(c, &inspection)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, errOrderNotFound):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errNotReturned), errors.Is(err, errAlreadyInspected):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errUnknownOrderItem):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errPhotoUpload):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload inspection photo"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save inspection", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, inspection)
+}
+
+// attachPhotos mengunggah foto tiap item lalu menyimpan URL-nya. Jika ada foto yang
+// gagal diunggah, klaim pemeriksaan dihapus agar toko bisa mengulang dari awal.
+func (h *Handler) attachPhotos(c *gin.Context, inspection *models.ReturnInspection) error {
+	form, _ := c.MultipartForm()
+	if form == nil {
+		return nil
+	}
+	for i := range inspection.Items {
+		item := &inspection.Items[i]
+		files := form.File["photos_"+item.OrderItemID.String()]
+		if len(files) == 0 {
+			continue
+		}
+		for _, file := range files {
+			url, err := h.Storage.Upload(photoBucket, file)
+			if err != nil {
+				log.Printf("Failed to upload inspection photo: %v", err)
+				h.discardInspection(inspection.ID)
+				return errPhotoUpload
+			}
+			item.Photos = append(item.Photos, url)
+		}
+		if err := h.DB.Model(item).Update("photos", item.Photos).Error; err != nil {
+			log.Printf("Failed to save photos of inspection item %s: %v", item.ID, err)
+			h.discardInspection(inspection.ID)
+			return err
+		}
+	}
+	return nil
+}
+
+// discardInspection menghapus pemeriksaan yang fotonya gagal disimpan
+func (h *Handler) discardInspection(inspectionID uuid.UUID) {
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("inspection_id = ?", inspectionID).Delete(&models.InspectionItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ReturnInspection{}, "id = ?", inspectionID).Error
+	})
+	if err != nil {
+		log.Printf("Failed to discard inspection %s: %v", inspectionID, err)
+	}
+}
+
+// GetInspection menampilkan hasil pemeriksaan order beserta klaim kerusakannya.
+// Bisa diakses oleh penyewa pemilik order maupun toko yang menerima order.
+func (h *Handler) GetInspection(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var order models.Order
+	if err := h.DB.Preload("Shop").First(&order, "id = ?", c.Param("orderId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if order.UserID != userID && order.Shop.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to view this order"})
+		return
+	}
+
+	var inspection models.ReturnInspection
+	if err := h.DB.Preload("Items").Where("order_id = ?", order.ID).First(&inspection).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order has not been inspected yet"})
+		return
+	}
+
+	var claims []models.DamageClaim
+	h.DB.Where("order_id = ?", order.ID).Order("created_at ASC").Find(&claims)
+	if claims == nil {
+		claims = make([]models.DamageClaim, 0)
+	}
+	c.JSON(http.StatusOK, gin.H{"inspection": inspection, "claims": claims})
+}
+
+type DamageClaimPayload struct {
+	Amount      int    `json:"amount" binding:"required,gt=0"`
+	Description string `json:"description" binding:"required"`
+}
+
+// CreateDamageClaim mengajukan tagihan kerusakan berdasarkan hasil pemeriksaan.
+// Order tidak bisa diselesaikan sampai penyewa menerima klaim atau toko menariknya.
+func (h *Handler) CreateDamageClaim(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": errOrderNotFound.Error()})
+		return
+	}
+
+	var payload DamageClaimPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	var claim models.DamageClaim
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		order, err := findShopOrder(tx, shopID, c.Param("orderId"))
+		if err != nil {
+			return err
+		}
+		if order.Status != lifecycle.StatusReturned {
+			return errNotReturned
+		}
+
+		var inspection models.ReturnInspection
+		if err := tx.Preload("Items").Where("order_id = ?", order.ID).First(&inspection).Error; err != nil {
+			return errNoInspection
+		}
+		damaged := false
+		for _, item := range inspection.Items {
+			if item.Condition != ConditionGood || len(item.MissingParts) > 0 {
+				damaged = true
+			}
+		}
+		if !damaged {
+			return errNoDamage
+		}
+
+		open, err := lifecycle.HasOpenClaim(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if open {
+			return errClaimAlreadyFiled
+		}
+
+		claim = models.DamageClaim{
+			ID:           uuid.New(),
+			OrderID:      order.ID,
+			InspectionID: inspection.ID,
+			Amount:       payload.Amount,
+			Description:  payload.Description,
+			Status:       lifecycle.ClaimOpen,
+		}
+		return tx.Create(&claim).Error
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, errOrderNotFound):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, errNotReturned), errors.Is(err, errNoInspection), errors.Is(err, errNoDamage), errors.Is(err, errClaimAlreadyFiled):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create damage claim", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, claim)
+}
+
+type ClaimResponsePayload struct {
+	Note string `json:"note"`
+}
+
+// AcceptDamageClaim dipakai penyewa untuk menerima klaim kerusakan.
+// Nilai klaim langsung dipotong dari deposit order sebanyak sisa deposit yang ada.
+func (h *Handler) AcceptDamageClaim(c *gin.Context) {
+	h.respondToClaim(c, lifecycle.ClaimAccepted)
+}
+
+// DisputeDamageClaim dipakai penyewa untuk menyanggah klaim kerusakan.
+// Klaim tetap menahan order sampai toko menariknya.
+func (h *Handler) DisputeDamageClaim(c *gin.Context) {
+	h.respondToClaim(c, lifecycle.ClaimDisputed)
+}
+
+func (h *Handler) respondToClaim(c *gin.Context, status string) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var payload ClaimResponsePayload
+	// Body opsional saat menerima klaim, wajib berisi note saat menyanggah
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	if status == lifecycle.ClaimDisputed && payload.Note == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "note is required to dispute a claim"})
+		return
+	}
+
+	var claim models.DamageClaim
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND user_id = ?", c.Param("orderId"), userID).First(&order).Error; err != nil {
+			return errOrderNotFound
+		}
+		if err := tx.Where("id = ? AND order_id = ?", c.Param("claimId"), order.ID).First(&claim).Error; err != nil {
+			return errClaimNotFound
+		}
+		if claim.Status != lifecycle.ClaimOpen {
+			return errClaimNotOpen
+		}
+
+		now := time.Now()
+		claim.Status = status
+		claim.RenterNote = payload.Note
+		claim.RespondedAt = &now
+		if status == lifecycle.ClaimAccepted {
+			var evidence []string
+			var items []models.InspectionItem
+			tx.Where("inspection_id = ?", claim.InspectionID).Find(&items)
+			for _, item := range items {
+				evidence = append(evidence, item.Photos...)
+			}
+			deducted, err := deposit.Deduct(tx, order.ID, deposit.Deduction{
+				Amount:   claim.Amount,
+				Reason:   "damage claim accepted: " + claim.Description,
+				Evidence: evidence,
+			}, &userID)
+			if err != nil {
+				return err
+			}
+			claim.DeductedFromDeposit = deducted
+		}
+		return tx.Save(&claim).Error
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, errOrderNotFound), errors.Is(err, errClaimNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, errClaimNotOpen):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to respond to damage claim", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, claim)
+}
+
+// WithdrawDamageClaim dipakai toko untuk menarik klaim yang masih open atau disanggah
+func (h *Handler) WithdrawDamageClaim(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": errOrderNotFound.Error()})
+		return
+	}
+
+	var claim models.DamageClaim
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		order, err := findShopOrder(tx, shopID, c.Param("orderId"))
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND order_id = ?", c.Param("claimId"), order.ID).First(&claim).Error; err != nil {
+			return errClaimNotFound
+		}
+		if claim.Status != lifecycle.ClaimOpen && claim.Status != lifecycle.ClaimDisputed {
+			return errClaimNotOpen
+		}
+		claim.Status = lifecycle.ClaimWithdrawn
+		return tx.Save(&claim).Error
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, errOrderNotFound), errors.Is(err, errClaimNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, errClaimNotOpen):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to withdraw damage claim", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, claim)
+}
+
+// findShopOrder mencari order dan memastikan order itu milik toko shopID.
+// Baris order dikunci jika dipanggil di dalam transaksi.
+func findShopOrder(tx *gorm.DB, shopID uuid.UUID, orderID string) (models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND shop_id = ?", orderID, shopID).First(&order).Error; err != nil {
+		return models.Order{}, errOrderNotFound
+	}
+	return order, nil
+}
+
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return uuid.Nil, false
+	}
+	userIDString, _ := userIDInterface.(string)
+	userID, err := uuid.Parse(userIDString)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
@@ -0,0 +1,201 @@
+// Lokasi: internal/lifecycle/lifecycle.go
+package lifecycle
+
+import (
+	"errors"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Status order yang dikenal oleh sistem
+const (
+	StatusPending          = "pending"
+	StatusAwaitingApproval = "awaiting_approval"
+	StatusPaid             = "paid"
+	StatusPickedUp         = "picked_up"
+	StatusReturned         = "returned"
+	StatusCompleted        = "completed"
+	StatusCancelled        = "cancelled"
+	StatusExpired          = "expired"
+	StatusRefunded         = "refunded"
+)
+
+// Actor adalah pihak yang memicu perubahan status order
+type Actor string
+
+const (
+	ActorRenter Actor = "renter"
+	ActorVendor Actor = "vendor"
+	ActorSystem Actor = "system"
+	ActorAdmin  Actor = "admin"
+)
+
+// HoldingStatuses adalah status order yang masih menahan stok produk
+var HoldingStatuses = []string{StatusPending, StatusAwaitingApproval, StatusPaid, StatusPickedUp}
+
+// Status klaim kerusakan. Klaim open atau disputed menahan order agar tidak bisa completed.
+const (
+	ClaimOpen      = "open"
+	ClaimAccepted  = "accepted"
+	ClaimDisputed  = "disputed"
+	ClaimWithdrawn = "withdrawn"
+)
+
+// OpenClaimStatuses adalah status klaim kerusakan yang belum selesai
+var OpenClaimStatuses = []string{ClaimOpen, ClaimDisputed}
+
+var (
+	ErrUnknownStatus     = errors.New("unknown order status")
+	ErrInvalidTransition = errors.New("order cannot move to the requested status from its current status")
+	ErrActorNotAllowed   = errors.New("you are not allowed to move the order to the requested status")
+	ErrStatusChanged     = errors.New("order status was changed by another request, please try again")
+	ErrOpenClaim         = errors.New("order has an unresolved damage claim")
+	ErrNotAwaiting       = errors.New("order is not waiting for approval")
+	ErrApprovalExpired   = errors.New("approval deadline has passed, the order will be cancelled and refunded")
+)
+
+// transitions memetakan status asal ke status tujuan beserta pihak yang boleh memicunya.
+// Admin boleh melakukan semua transisi yang terdaftar di sini. awaiting_approval -> paid
+// sengaja tidak ada di sini karena hanya boleh lewat Approve yang mengecek batas waktunya.
+var transitions = map[string]map[string][]Actor{
+	StatusPending: {
+		StatusPaid:             {ActorSystem},
+		StatusAwaitingApproval: {ActorSystem},
+		StatusCancelled:        {ActorRenter, ActorVendor, ActorSystem},
+		StatusExpired:          {ActorSystem},
+	},
+	StatusAwaitingApproval: {
+		StatusCancelled: {ActorRenter, ActorVendor, ActorSystem},
+	},
+	StatusPaid: {
+		StatusPickedUp:  {ActorVendor},
+		StatusCancelled: {ActorRenter, ActorVendor},
+		StatusRefunded:  {ActorSystem},
+	},
+	StatusPickedUp: {
+		StatusReturned: {ActorVendor},
+	},
+	StatusReturned: {
+		StatusCompleted: {ActorVendor, ActorSystem},
+	},
+	StatusCancelled: {
+		StatusRefunded: {ActorSystem},
+	},
+}
+
+// IsKnown mengecek apakah status termasuk status yang dikenal
+func IsKnown(status string) bool {
+	switch status {
+	case StatusPending, StatusAwaitingApproval, StatusPaid, StatusPickedUp, StatusReturned,
+		StatusCompleted, StatusCancelled, StatusExpired, StatusRefunded:
+		return true
+	}
+	return false
+}
+
+// CanTransition mengecek apakah actor boleh memindahkan order dari status from ke status to
+func CanTransition(from, to string, actor Actor) error {
+	if !IsKnown(to) {
+		return ErrUnknownStatus
+	}
+	allowed, ok := transitions[from][to]
+	if !ok {
+		return ErrInvalidTransition
+	}
+	if actor == ActorAdmin {
+		return nil
+	}
+	for _, a := range allowed {
+		if a == actor {
+			return nil
+		}
+	}
+	return ErrActorNotAllowed
+}
+
+// Transition memindahkan status order dan mencatatnya ke order_status_history
+func Transition(tx *gorm.DB, order *models.Order, to string, actor Actor, actorID *uuid.UUID, reason string) error {
+	if err := CanTransition(order.Status, to, actor); err != nil {
+		return err
+	}
+	return apply(tx, order, to, actor, actorID, reason)
+}
+
+// Approve memindahkan order awaiting_approval menjadi paid atas persetujuan toko,
+// selama batas waktu persetujuannya belum lewat
+func Approve(tx *gorm.DB, order *models.Order, actorID *uuid.UUID, now time.Time) error {
+	if order.Status != StatusAwaitingApproval {
+		return ErrNotAwaiting
+	}
+	if order.ApprovalDeadline != nil && now.After(*order.ApprovalDeadline) {
+		return ErrApprovalExpired
+	}
+	return apply(tx, order, StatusPaid, ActorVendor, actorID, "approved by shop")
+}
+
+// apply mengubah status tanpa mengecek tabel transitions. Update hanya berhasil
+// jika status di database masih sama dengan order.Status, sehingga dua request
+// yang berlomba tidak bisa sama-sama mengubah status.
+func apply(tx *gorm.DB, order *models.Order, to string, actor Actor, actorID *uuid.UUID, reason string) error {
+	if to == StatusCompleted {
+		open, err := HasOpenClaim(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if open {
+			return ErrOpenClaim
+		}
+	}
+
+	result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, order.Status).Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStatusChanged
+	}
+
+	from := order.Status
+	order.Status = to
+	return record(tx, order.ID, from, to, actor, actorID, reason)
+}
+
+// HasOpenClaim mengecek apakah order masih punya klaim kerusakan yang belum selesai
+func HasOpenClaim(tx *gorm.DB, orderID uuid.UUID) (bool, error) {
+	var count int64
+	err := tx.Model(&models.DamageClaim{}).Where("order_id = ? AND status IN ?", orderID, OpenClaimStatuses).Count(&count).Error
+	return count > 0, err
+}
+
+// RecordCreated mencatat status awal order ke riwayat saat order baru dibuat
+func RecordCreated(tx *gorm.DB, order *models.Order, actor Actor, actorID *uuid.UUID) error {
+	return record(tx, order.ID, "", order.Status, actor, actorID, "order created")
+}
+
+// Timeline mengambil riwayat status order dari yang paling lama
+func Timeline(db *gorm.DB, orderID uuid.UUID) ([]models.OrderStatusHistory, error) {
+	var history []models.OrderStatusHistory
+	err := db.Where("order_id = ?", orderID).Order("created_at ASC").Find(&history).Error
+	if history == nil {
+		history = make([]models.OrderStatusHistory, 0)
+	}
+	return history, err
+}
+
+func record(tx *gorm.DB, orderID uuid.UUID, from, to string, actor Actor, actorID *uuid.UUID, reason string) error {
+	entry := models.OrderStatusHistory{
+		ID:         uuid.New(),
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      string(actor),
+		ActorID:    actorID,
+		Reason:     reason,
+		CreatedAt:  time.Now(),
+	}
+	return tx.Create(&entry).Error
+}
@@ -0,0 +1,58 @@
+package lifecycle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  string
+		to    string
+		actor Actor
+		want  error
+	}{
+		{"system marks pending order paid", StatusPending, StatusPaid, ActorSystem, nil},
+		{"renter cannot mark order paid", StatusPending, StatusPaid, ActorRenter, ErrActorNotAllowed},
+		{"vendor hands over paid order", StatusPaid, StatusPickedUp, ActorVendor, nil},
+		{"vendor cannot skip pickup", StatusPaid, StatusReturned, ActorVendor, ErrInvalidTransition},
+		{"renter cancels awaiting approval", StatusAwaitingApproval, StatusCancelled, ActorRenter, nil},
+		{"vendor cannot approve through a status update", StatusAwaitingApproval, StatusPaid, ActorVendor, ErrInvalidTransition},
+		{"admin cannot approve through a status update", StatusAwaitingApproval, StatusPaid, ActorAdmin, ErrInvalidTransition},
+		{"admin may use any listed transition", StatusPickedUp, StatusReturned, ActorAdmin, nil},
+		{"unknown target status", StatusPaid, "active", ActorAdmin, ErrUnknownStatus},
+		{"completed is final", StatusCompleted, StatusCancelled, ActorAdmin, ErrInvalidTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CanTransition(tt.from, tt.to, tt.actor); !errors.Is(err, tt.want) {
+				t.Errorf("CanTransition(%s, %s, %s) = %v, want %v", tt.from, tt.to, tt.actor, err, tt.want)
+			}
+		})
+	}
+}
+
+// Approve menolak sebelum menyentuh database, sehingga tx nil aman untuk kasus gagal
+func TestApproveRejects(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	tests := []struct {
+		name  string
+		order models.Order
+		want  error
+	}{
+		{"order is not awaiting approval", models.Order{Status: StatusPaid}, ErrNotAwaiting},
+		{"deadline has passed", models.Order{Status: StatusAwaitingApproval, ApprovalDeadline: &past}, ErrApprovalExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Approve(nil, &tt.order, nil, now); !errors.Is(err, tt.want) {
+				t.Errorf("Approve() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Lokasi: internal/mailer/mailer.go
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message adalah email teks biasa yang akan dikirim
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer mengirim email. Implementasinya bisa diganti lewat konfigurasi MAILER.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPConfig adalah pengaturan server SMTP
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// New membuat mailer sesuai konfigurasi: "smtp", "file" (menulis .eml ke dir)
+// atau "memory" (hanya disimpan di memori)
+func New(kind string, smtpConfig SMTPConfig, dir string) (Mailer, error) {
+	switch kind {
+	case "smtp":
+		return &SMTPMailer{Config: smtpConfig}, nil
+	case "file", "":
+		return &FileMailer{Dir: dir, From: smtpConfig.From}, nil
+	case "memory":
+		return &MemoryMailer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", kind)
+	}
+}
+
+// SMTPMailer mengirim email lewat server SMTP dengan autentikasi PLAIN
+type SMTPMailer struct {
+	Config SMTPConfig
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.Config.Username != "" {
+		auth = smtp.PlainAuth("", m.Config.Username, m.Config.Password, m.Config.Host)
+	}
+	addr := m.Config.Host + ":" + m.Config.Port
+	return smtp.SendMail(addr, auth, m.Config.From, []string{msg.To}, format(m.Config.From, msg))
+}
+
+// FileMailer menulis setiap email sebagai file .eml, untuk development lokal
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), uuid.New().String()[:8])
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg), 0o644)
+}
+
+// MemoryMailer menyimpan email yang dikirim di memori, untuk pengujian
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Sent mengembalikan salinan semua email yang sudah dikirim
+func (m *MemoryMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
+
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
@@ -2,15 +2,19 @@
 package middleware
 
 import (
-	"errors"
+	"log"
 	"net/http"
 	"strings"
 
+	"sewascaf.com/api/internal/session"
+	"sewascaf.com/api/internal/twofactor"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+func AuthMiddleware(sessions *session.Issuer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -25,27 +29,78 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		tokenString := headerParts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("unexpected signing method")
-			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := sessions.Parse(headerParts[1])
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Failed to parse token claims"})
+		// Token yang sesinya sudah logout atau dicabut ditolak walaupun belum kedaluwarsa
+		if err := sessions.Active(claims.SessionID); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has been logged out or revoked"})
 			return
 		}
 
-		// Simpan ID user di context, agar bisa diakses oleh handler selanjutnya
-		c.Set("userID", claims["sub"])
+		// Simpan ID user, sesi, role, dan toko di context, agar bisa diakses oleh handler selanjutnya
+		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("role", claims.Role)
+		c.Set("twoFactor", claims.TwoFactor)
+		if claims.ShopID != nil {
+			c.Set("shopID", *claims.ShopID)
+		}
 		c.Next() // Lanjutkan request ke handler utama
 	}
-}
\ No newline at end of file
+}
+
+// ShopID mengembalikan ID toko dari claim token yang disimpan AuthMiddleware,
+// sehingga handler toko tidak perlu mencari toko berdasarkan user_id.
+// ok bernilai false jika token tidak membawa toko.
+func ShopID(c *gin.Context) (uuid.UUID, bool) {
+	value, exists := c.Get("shopID")
+	if !exists {
+		return uuid.Nil, false
+	}
+	shopID, ok := value.(uuid.UUID)
+	return shopID, ok
+}
+
+// RequireRole hanya meneruskan request jika role di token termasuk salah satu roles.
+// Harus dipasang setelah AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
+	}
+}
+
+// RequireTwoFactor menolak request dari user yang belum mengaktifkan 2FA jika
+// admin mewajibkan 2FA untuk role-nya. Harus dipasang setelah AuthMiddleware.
+func RequireTwoFactor(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("twoFactor") {
+			c.Next()
+			return
+		}
+		required, err := twofactor.Required(db, c.GetString("role"))
+		if err != nil {
+			log.Printf("Failed to load two-factor policy: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor policy"})
+			return
+		}
+		if required {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":                     "Two-factor authentication must be enabled to access vendor features",
+				"two_factor_setup_required": true,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+// Lokasi: internal/middleware/ratelimit.go
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sewascaf.com/api/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit membatasi jumlah request per window untuk satu grup route (name).
+// Request dihitung per user jika sudah melewati AuthMiddleware, selain itu per IP.
+// Limit 0 mematikan pembatasan. Jika store gagal, request tetap diteruskan.
+func RateLimit(store ratelimit.Store, name string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		key := name + ":ip:" + c.ClientIP()
+		if userID := c.GetString("userID"); userID != "" {
+			key = name + ":user:" + userID
+		}
+		count, reset, err := store.Hit(c.Request.Context(), key, window)
+		if err != nil {
+			log.Printf("Rate limit store error for %s: %v", key, err)
+			c.Next()
+			return
+		}
+
+		remaining := limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if count > limit {
+			retryAfter := int(math.Ceil(time.Until(reset).Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later", "retry_after": retryAfter})
+			return
+		}
+		c.Next()
+	}
+}
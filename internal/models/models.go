@@ -21,6 +21,54 @@ func (j *JSONB) Scan(src interface{}) error {
     }
     return json.Unmarshal(source, &j)
 }
+func (j JSONB) Contains(value string) bool {
+	for _, v := range j {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONRaw menyimpan JSON apa adanya (misalnya instruksi pembayaran Tripay) ke kolom jsonb
+type JSONRaw json.RawMessage
+func (j JSONRaw) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+func (j *JSONRaw) Scan(src interface{}) error {
+	switch source := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[0:0], source...)
+	case string:
+		*j = JSONRaw(source)
+	default:
+		return errors.New("type assertion .([]byte) failed")
+	}
+	return nil
+}
+func (j JSONRaw) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+func (j *JSONRaw) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[0:0], data...)
+	return nil
+}
+
+// Role user. Role pengusaha hanya bisa didapat lewat upgrade ke vendor,
+// role admin hanya diberikan langsung di database.
+const (
+	RoleUser      = "user"
+	RolePengusaha = "pengusaha"
+	RoleAdmin     = "admin"
+)
 
 type User struct {
 	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
@@ -31,6 +79,14 @@ type User struct {
 	Alamat    string    `json:"alamat"`
 	Telepon   string    `json:"telepon"`
 	Role      string    `json:"role"`
+	Latitude  *float64  `json:"latitude"`
+	Longitude *float64  `json:"longitude"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	FailedLoginAttempts int        `json:"-" gorm:"default:0"`
+	LockedUntil         *time.Time `json:"-"`
+	TwoFactorSecret     string     `json:"-"`
+	TwoFactorEnabledAt  *time.Time `json:"two_factor_enabled_at"`
+	TwoFactorLastStep   int64      `json:"-"` // Langkah TOTP terakhir yang dipakai, mencegah kode dipakai ulang
 }
 
 type Shop struct {
@@ -44,6 +100,15 @@ type Shop struct {
 	ShopProfileImageURL string    `json:"shop_profile_image_url"`
 	ShopNameLastUpdated *time.Time `json:"shop_name_last_updated"`
 	ActivePaymentChannels JSONB `json:"active_payment_channels" gorm:"type:jsonb"`
+	CancellationFullRefundDays    int `json:"cancellation_full_refund_days" gorm:"default:3"`
+	CancellationPartialRefundPercent int `json:"cancellation_partial_refund_percent" gorm:"default:50"`
+	RequiresApproval    bool `json:"requires_approval"`
+	ApprovalWindowHours int  `json:"approval_window_hours" gorm:"default:24"`
+	DeliveryEnabled     bool   `json:"delivery_enabled"`
+	DeliveryFeeType     string `json:"delivery_fee_type" gorm:"default:flat"`
+	DeliveryFlatFee     int    `json:"delivery_flat_fee"`
+	Latitude            *float64 `json:"latitude"`
+	Longitude           *float64 `json:"longitude"`
 }
 
 type Product struct {
@@ -58,6 +123,11 @@ type Product struct {
 	DiscountPricePerDay int       `json:"discount_price_per_day"`  
 	Stock               int       `json:"stock"`                 
 	ImageURL            string    `json:"image_url"`
+	PricePerWeek        int       `json:"price_per_week"`
+	PricePerMonth       int       `json:"price_per_month"`
+	MinRentalDays       int       `json:"min_rental_days"`
+	MaxRentalDays       int       `json:"max_rental_days"`
+	DepositAmount       int       `json:"deposit_amount"`
 	Reviews             []Review  `json:"reviews" gorm:"foreignKey:ProductID"`
 }
 
@@ -74,6 +144,26 @@ type Order struct {
 	CreatedAt  time.Time `json:"created_at"`
 	OrderItems []OrderItem `json:"items" gorm:"foreignKey:OrderID"`
 	PaymentMethod string    `json:"payment_method"`
+	DepositAmount int       `json:"deposit_amount"`
+	OverdueAt     *time.Time `json:"overdue_at"`
+	LateDays      int       `json:"late_days"`
+	LateFee       int       `json:"late_fee"`
+	ApprovalDeadline *time.Time `json:"approval_deadline"`
+	FulfillmentMethod      string     `json:"fulfillment_method" gorm:"default:pickup"`
+	DeliveryAddress        string     `json:"delivery_address"`
+	DeliveryNotes          string     `json:"delivery_notes"`
+	DeliveryZoneID         *uuid.UUID `json:"delivery_zone_id" gorm:"type:uuid"`
+	DeliveryFee            int        `json:"delivery_fee"`
+	FulfillmentWindowStart *time.Time `json:"fulfillment_window_start"`
+	FulfillmentWindowEnd   *time.Time `json:"fulfillment_window_end"`
+	DeliveryLatitude       *float64   `json:"delivery_latitude"`
+	DeliveryLongitude      *float64   `json:"delivery_longitude"`
+	DeliveryDistanceKm     *float64   `json:"delivery_distance_km"`
+}
+
+// ChargeAmount adalah jumlah yang ditagihkan ke penyewa: harga sewa (termasuk ongkos kirim) ditambah deposit
+func (o Order) ChargeAmount() int {
+	return o.TotalPrice + o.DepositAmount
 }
 
 type Review struct {
@@ -94,6 +184,10 @@ type OrderItem struct {
 	Product            Product   `json:"-" gorm:"foreignKey:ProductID"`
 	Quantity           int       `json:"quantity"`
 	PriceAtTimeOfOrder int       `json:"price_at_time_of_order"` 
+	RentalDays         int       `json:"rental_days"`
+	Subtotal           int       `json:"subtotal"`
+	PriceBreakdown     JSONRaw   `json:"price_breakdown" gorm:"type:jsonb"`
+	DepositPerUnit     int       `json:"deposit_per_unit"`
 }
 
 type Bookmark struct {
@@ -111,4 +205,289 @@ type ChatHistory struct {
 	Question  string    `json:"question"`
 	Answer    string    `json:"answer"`
 	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+}
+
+// Payment menyimpan transaksi Tripay yang dibuat untuk sebuah order
+// agar user bisa melanjutkan pembayaran walaupun aplikasi sempat ditutup
+type Payment struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	OrderID       uuid.UUID  `json:"order_id" gorm:"type:uuid;index"`
+	Order         Order      `json:"-" gorm:"foreignKey:OrderID"`
+	Reference     string     `json:"reference" gorm:"index"`
+	MerchantRef   string     `json:"merchant_ref"`
+	PaymentMethod string     `json:"payment_method"`
+	PaymentName   string     `json:"payment_name"`
+	Amount        int        `json:"amount"`
+	FeeMerchant   int        `json:"fee_merchant"`
+	FeeCustomer   int        `json:"fee_customer"`
+	TotalAmount   int        `json:"total_amount"`
+	PayCode       string     `json:"pay_code"`
+	PayURL        string     `json:"pay_url"`
+	CheckoutURL   string     `json:"checkout_url"`
+	QRURL         string     `json:"qr_url"`
+	Instructions  JSONRaw    `json:"instructions" gorm:"type:jsonb"`
+	Status        string     `json:"status"`
+	ExpiredAt     *time.Time `json:"expired_at"`
+	PaidAt        *time.Time `json:"paid_at"`
+	Purpose       string     `json:"purpose" gorm:"default:order"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// OrderStatusHistory mencatat setiap perpindahan status order
+type OrderStatusHistory struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	OrderID    uuid.UUID  `json:"order_id" gorm:"type:uuid;index"`
+	Order      Order      `json:"-" gorm:"foreignKey:OrderID"`
+	FromStatus string     `json:"from_status"`
+	ToStatus   string     `json:"to_status"`
+	Actor      string     `json:"actor"`
+	ActorID    *uuid.UUID `json:"actor_id" gorm:"type:uuid"`
+	Reason     string     `json:"reason"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+// PaymentEvent mencatat setiap callback Tripay yang diterima. Kombinasi
+// reference dan status unik sehingga callback yang dikirim ulang tidak diproses dua kali.
+type PaymentEvent struct {
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	Reference      string    `json:"reference" gorm:"uniqueIndex:idx_payment_event_reference_status"`
+	Status         string    `json:"status" gorm:"uniqueIndex:idx_payment_event_reference_status"`
+	MerchantRef    string    `json:"merchant_ref"`
+	TotalAmount    int       `json:"total_amount"`
+	AmountReceived int       `json:"amount_received"`
+	Result         string    `json:"result"`
+	Note           string    `json:"note"`
+	Payload        JSONRaw   `json:"payload" gorm:"type:jsonb"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CartItem adalah produk di keranjang user yang belum di-checkout
+type CartItem struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	User      User      `json:"-" gorm:"foreignKey:UserID"`
+	ProductID uuid.UUID `json:"product_id" gorm:"type:uuid"`
+	Product   Product   `json:"-" gorm:"foreignKey:ProductID"`
+	Quantity  int       `json:"quantity"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SeasonalPrice adalah harga harian khusus produk pada rentang tanggal tertentu
+// (tanggal akhir ikut dihitung), misalnya saat musim proyek
+type SeasonalPrice struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	ProductID   uuid.UUID `json:"product_id" gorm:"type:uuid;index"`
+	Product     Product   `json:"-" gorm:"foreignKey:ProductID"`
+	Label       string    `json:"label"`
+	StartDate   time.Time `json:"start_date"`
+	EndDate     time.Time `json:"end_date"`
+	PricePerDay int       `json:"price_per_day"`
+}
+// DepositEntry adalah satu catatan di buku deposit order: hold saat order dibayar,
+// lalu release dan/atau deduction saat deposit diselesaikan
+type DepositEntry struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	OrderID   uuid.UUID  `json:"order_id" gorm:"type:uuid;index"`
+	Order     Order      `json:"-" gorm:"foreignKey:OrderID"`
+	Type      string     `json:"type"`
+	Amount    int        `json:"amount"`
+	Reason    string     `json:"reason"`
+	Evidence  JSONB      `json:"evidence" gorm:"type:jsonb"`
+	ActorID   *uuid.UUID `json:"actor_id" gorm:"type:uuid"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ReturnInspection adalah hasil pemeriksaan barang oleh toko saat order dikembalikan
+type ReturnInspection struct {
+	ID          uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;"`
+	OrderID     uuid.UUID        `json:"order_id" gorm:"type:uuid;uniqueIndex"`
+	Order       Order            `json:"-" gorm:"foreignKey:OrderID"`
+	InspectorID uuid.UUID        `json:"inspector_id" gorm:"type:uuid"`
+	Notes       string           `json:"notes"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Items       []InspectionItem `json:"items" gorm:"foreignKey:InspectionID"`
+}
+
+// InspectionItem adalah kondisi satu item order saat dikembalikan
+type InspectionItem struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	InspectionID uuid.UUID `json:"inspection_id" gorm:"type:uuid;index"`
+	OrderItemID  uuid.UUID `json:"order_item_id" gorm:"type:uuid"`
+	Condition    string    `json:"condition"`
+	MissingParts JSONB     `json:"missing_parts" gorm:"type:jsonb"`
+	Photos       JSONB     `json:"photos" gorm:"type:jsonb"`
+	Notes        string    `json:"notes"`
+}
+
+// DamageClaim adalah tagihan kerusakan dari toko yang harus diterima atau
+// disanggah oleh penyewa sebelum order bisa diselesaikan
+type DamageClaim struct {
+	ID                  uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	OrderID             uuid.UUID  `json:"order_id" gorm:"type:uuid;index"`
+	Order               Order      `json:"-" gorm:"foreignKey:OrderID"`
+	InspectionID        uuid.UUID  `json:"inspection_id" gorm:"type:uuid"`
+	Amount              int        `json:"amount"`
+	Description         string     `json:"description"`
+	Status              string     `json:"status"`
+	RenterNote          string     `json:"renter_note"`
+	DeductedFromDeposit int        `json:"deducted_from_deposit"`
+	CreatedAt           time.Time  `json:"created_at"`
+	RespondedAt         *time.Time `json:"responded_at"`
+}
+
+// Notification adalah pemberitahuan di dalam aplikasi untuk seorang user
+type Notification struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+	Type      string     `json:"type"`
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	OrderID   *uuid.UUID `json:"order_id" gorm:"type:uuid"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OrderExtension adalah permintaan perpanjangan masa sewa sebuah order.
+// EndDate order baru diubah setelah pembayaran perpanjangan dikonfirmasi Tripay.
+type OrderExtension struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	OrderID        uuid.UUID  `json:"order_id" gorm:"type:uuid;index"`
+	Order          Order      `json:"-" gorm:"foreignKey:OrderID"`
+	OldEndDate     time.Time  `json:"old_end_date"`
+	NewEndDate     time.Time  `json:"new_end_date"`
+	Amount         int        `json:"amount"`
+	PriceBreakdown JSONRaw    `json:"price_breakdown" gorm:"type:jsonb"`
+	Status         string     `json:"status"`
+	Reference      string     `json:"reference" gorm:"index"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ConfirmedAt    *time.Time `json:"confirmed_at"`
+}
+
+// Refund adalah pengembalian dana ke penyewa saat order dibatalkan setelah dibayar
+type Refund struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	OrderID          uuid.UUID  `json:"order_id" gorm:"type:uuid;index"`
+	Order            Order      `json:"-" gorm:"foreignKey:OrderID"`
+	PaymentReference string     `json:"payment_reference"`
+	Rule             string     `json:"rule"`
+	Percent          int        `json:"percent"`
+	RentalRefund     int        `json:"rental_refund"`
+	DepositRefund    int        `json:"deposit_refund"`
+	Amount           int        `json:"amount"`
+	Reason           string     `json:"reason"`
+	Provider         string     `json:"provider"`
+	ProviderRef      string     `json:"provider_ref"`
+	Status           string     `json:"status"`
+	FailureReason    string     `json:"failure_reason"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ProcessedAt      *time.Time `json:"processed_at"`
+}
+
+// DeliveryZone adalah zona antar sebuah toko: alamat sampai MaxDistanceKm dari
+// toko dikenakan ongkos kirim Fee
+type DeliveryZone struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	ShopID        uuid.UUID `json:"shop_id" gorm:"type:uuid;index"`
+	Shop          Shop      `json:"-" gorm:"foreignKey:ShopID"`
+	Name          string    `json:"name"`
+	MaxDistanceKm float64   `json:"max_distance_km"`
+	Fee           int       `json:"fee"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MaintenanceBlock adalah sejumlah unit produk yang tidak bisa disewa karena
+// perbaikan atau perawatan (tanggal akhir ikut dihitung)
+type MaintenanceBlock struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	ProductID uuid.UUID `json:"product_id" gorm:"type:uuid;index"`
+	Product   Product   `json:"-" gorm:"foreignKey:ProductID"`
+	Quantity  int       `json:"quantity"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ShopClosure adalah hari libur toko (tanggal akhir ikut dihitung). Barang tidak
+// bisa diambil atau dikembalikan pada hari tersebut.
+type ShopClosure struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	ShopID    uuid.UUID `json:"shop_id" gorm:"type:uuid;index"`
+	Shop      Shop      `json:"-" gorm:"foreignKey:ShopID"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session adalah satu sesi login. Refresh token hanya disimpan dalam bentuk hash
+// dan dirotasi setiap kali dipakai.
+type Session struct {
+	ID                uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID            uuid.UUID  `json:"-" gorm:"type:uuid;index"`
+	User              User       `json:"-" gorm:"foreignKey:UserID"`
+	RefreshTokenHash  string     `json:"-" gorm:"uniqueIndex"`
+	PreviousTokenHash string     `json:"-" gorm:"index"`
+	UserAgent         string     `json:"user_agent"`
+	IPAddress         string     `json:"ip_address"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	// TwoFactorVerifiedAt terisi jika sesi ini dibuat atau dikonfirmasi dengan kode 2FA
+	TwoFactorVerifiedAt *time.Time `json:"two_factor_verified_at"`
+}
+
+// AuthToken adalah token sekali pakai yang dikirim lewat email untuk verifikasi
+// email atau reset password, juga ID challenge login 2FA. Hanya hash token yang disimpan.
+type AuthToken struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoginAttempt adalah catatan audit setiap percobaan login, berhasil maupun gagal.
+// UserID kosong jika email tidak terdaftar.
+type LoginAttempt struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID    *uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	Email     string     `json:"email" gorm:"index"`
+	IPAddress string     `json:"ip_address" gorm:"index"`
+	UserAgent string     `json:"user_agent"`
+	Success   bool       `json:"success"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index"`
+}
+
+// RecoveryCode adalah kode cadangan sekali pakai untuk login saat perangkat
+// authenticator tidak tersedia. Hanya hash kode yang disimpan.
+type RecoveryCode struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID    uuid.UUID  `json:"-" gorm:"type:uuid;index"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+	CodeHash  string     `json:"-" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// SecuritySettings adalah pengaturan keamanan platform yang diatur admin.
+// Hanya ada satu baris dengan ID 1.
+type SecuritySettings struct {
+	ID                     uint       `json:"-" gorm:"primaryKey"`
+	RequireVendorTwoFactor bool       `json:"require_vendor_two_factor" gorm:"default:false"`
+	UpdatedByID            *uuid.UUID `json:"updated_by_id" gorm:"type:uuid"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,49 @@
+// Lokasi: internal/notification/notification.go
+package notification
+
+import (
+	"time"
+
+	"sewascaf.com/api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Jenis notifikasi yang dikirim sistem
+const (
+	TypeOrderOverdue      = "order_overdue"
+	TypeLateFeeDue        = "late_fee_due"
+	TypeLateFeePaid       = "late_fee_paid"
+	TypeOrderExtended     = "order_extended"
+	TypeApprovalRequired  = "approval_required"
+	TypeOrderApproved     = "order_approved"
+	TypeOrderRejected     = "order_rejected"
+	TypeTwoFactorRequired = "two_factor_required"
+)
+
+// Notify menyimpan notifikasi untuk satu user. Dipanggil di dalam transaksi yang
+// sama dengan perubahan yang memicunya agar notifikasi tidak terkirim jika perubahan gagal.
+func Notify(tx *gorm.DB, userID uuid.UUID, kind, title, message string, orderID *uuid.UUID) error {
+	return tx.Create(&models.Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      kind,
+		Title:     title,
+		Message:   message,
+		OrderID:   orderID,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// NotifyOrderParties mengirim notifikasi yang sama ke penyewa dan pemilik toko sebuah order
+func NotifyOrderParties(tx *gorm.DB, order models.Order, kind, title, message string) error {
+	var shop models.Shop
+	if err := tx.Select("id", "user_id").First(&shop, "id = ?", order.ShopID).Error; err != nil {
+		return err
+	}
+	if err := Notify(tx, order.UserID, kind, title, message, &order.ID); err != nil {
+		return err
+	}
+	return Notify(tx, shop.UserID, kind, title, message, &order.ID)
+}
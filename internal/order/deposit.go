@@ -0,0 +1,43 @@
+// Lokasi: internal/order/deposit.go
+package order
+
+import (
+	"net/http"
+
+	"sewascaf.com/api/internal/deposit"
+	"sewascaf.com/api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOrderDeposit menampilkan saldo dan catatan deposit sebuah order.
+// Bisa diakses oleh penyewa pemilik order maupun toko yang menerima order.
+func (h *Handler) GetOrderDeposit(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+	userIDString, ok := userIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var order models.Order
+	if err := h.DB.Preload("Shop").First(&order, "id = ?", c.Param("orderId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if order.UserID.String() != userIDString && order.Shop.UserID.String() != userIDString {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to view this order"})
+		return
+	}
+
+	summary, err := deposit.Ledger(h.DB, order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deposit ledger"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"order_id": order.ID, "deposit_amount": order.DepositAmount, "ledger": summary})
+}
@@ -0,0 +1,256 @@
+// Lokasi: internal/order/extension.go
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/pricing"
+	"sewascaf.com/api/internal/reservation"
+	"sewascaf.com/api/internal/tripay"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errExtensionNotAllowed = errors.New("only paid or picked up orders that are not overdue can be extended")
+	errExtensionPending    = errors.New("order already has an extension waiting for payment")
+	errExtensionEndDate    = errors.New("new end_date must be after the current end_date")
+)
+
+type ExtensionPayload struct {
+	EndDate       string `json:"end_date" binding:"required"`
+	PaymentMethod string `json:"payment_method" binding:"required"`
+}
+
+// extensionLine adalah rincian harga perpanjangan satu item order
+type extensionLine struct {
+	ProductID   uuid.UUID `json:"product_id"`
+	ProductName string    `json:"product_name"`
+	Quantity    int       `json:"quantity"`
+	UnitPrice   int       `json:"unit_price"`
+	Amount      int       `json:"amount"`
+}
+
+// CreateExtension memperpanjang masa sewa order milik penyewa. Stok untuk periode
+// tambahan dicek dengan logika yang sama seperti CreateOrder dan ditahan sampai
+// batas pembayaran, yaitu PaymentTTL sampai Tripay memberi expired_time-nya.
+// EndDate order baru berubah setelah Tripay mengonfirmasi pembayaran.
+// Harga perpanjangan adalah selisih harga sewa dari StartDate sampai tanggal akhir
+// baru dengan harga sampai EndDate saat ini, sehingga tarif mingguan/bulanan tetap
+// berlaku dan hari dari perpanjangan sebelumnya tidak ditagih lagi.
+func (h *Handler) CreateExtension(c *gin.Context) {
+	userIDInterface, _ := c.Get("userID")
+	userIDString, _ := userIDInterface.(string)
+	userID, err := uuid.Parse(userIDString)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var payload ExtensionPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	newEndDate, err := time.Parse("2006-01-02", payload.EndDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format, use YYYY-MM-DD"})
+		return
+	}
+
+	var order models.Order
+	if err := h.DB.Preload("Shop").Where("id = ? AND user_id = ?", c.Param("orderId"), userID).First(&order).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if !order.Shop.ActivePaymentChannels.Contains(payload.PaymentMethod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment method " + payload.PaymentMethod + " is not accepted by this shop"})
+		return
+	}
+
+	var extension models.OrderExtension
+	var tripayItems []tripay.OrderItem
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var locked models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("OrderItems").First(&locked, "id = ?", order.ID).Error; err != nil {
+			return err
+		}
+		if (locked.Status != lifecycle.StatusPaid && locked.Status != lifecycle.StatusPickedUp) || locked.OverdueAt != nil {
+			return errExtensionNotAllowed
+		}
+		if !newEndDate.After(locked.EndDate) {
+			return errExtensionEndDate
+		}
+		if err := reservation.CheckShopOpen(tx, locked.ShopID, newEndDate); err != nil {
+			return err
+		}
+		var pending int64
+		tx.Model(&models.OrderExtension{}).
+			Where("order_id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)", locked.ID, reservation.ExtensionPending, time.Now()).
+			Count(&pending)
+		if pending > 0 {
+			return errExtensionPending
+		}
+
+		var productIDs []uuid.UUID
+		for _, item := range locked.OrderItems {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		products, err := reservation.LockProducts(tx, productIDs)
+		if err != nil {
+			return err
+		}
+		seasons, err := pricing.LoadSeasons(tx, productIDs, locked.StartDate, newEndDate)
+		if err != nil {
+			return err
+		}
+
+		// Batas pembayaran sementara sampai Tripay mengirim expired_time-nya, agar
+		// perpanjangan yang gagal ditagih tidak menahan stok selamanya
+		expiresAt := time.Now().Add(h.PaymentTTL)
+		extension = models.OrderExtension{
+			ID:         uuid.New(),
+			OrderID:    locked.ID,
+			OldEndDate: locked.EndDate,
+			NewEndDate: newEndDate,
+			Status:     reservation.ExtensionPending,
+			ExpiresAt:  &expiresAt,
+		}
+		var lines []extensionLine
+		for _, item := range locked.OrderItems {
+			product := products[item.ProductID]
+			available, err := reservation.AvailableForExtension(tx, product, locked.ID, locked.EndDate, newEndDate)
+			if err != nil {
+				return err
+			}
+			if available < item.Quantity {
+				return errors.New("stock for product " + product.Name + " is not available for the extended dates")
+			}
+
+			unitPrice, err := pricing.ExtensionPrice(product, seasons[product.ID], locked.StartDate, locked.EndDate, newEndDate)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, extensionLine{ProductID: product.ID, ProductName: product.Name, Quantity: item.Quantity, UnitPrice: unitPrice, Amount: unitPrice * item.Quantity})
+			extension.Amount += unitPrice * item.Quantity
+			if unitPrice > 0 {
+				tripayItems = append(tripayItems, tripay.OrderItem{SKU: product.SKU + "-EXT", Name: "Extension " + product.Name, Price: unitPrice, Quantity: item.Quantity})
+			}
+		}
+		breakdown, err := json.Marshal(lines)
+		if err != nil {
+			return err
+		}
+		extension.PriceBreakdown = models.JSONRaw(breakdown)
+
+		// Perpanjangan tanpa biaya tambahan langsung berlaku
+		if extension.Amount == 0 {
+			now := time.Now()
+			extension.Status = reservation.ExtensionConfirmed
+			extension.ConfirmedAt = &now
+			extension.ExpiresAt = nil
+			if err := tx.Model(&models.Order{}).Where("id = ?", locked.ID).Update("end_date", newEndDate).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&extension).Error
+	})
+
+	if err != nil {
+		var rentalErr *pricing.RentalError
+		var closedErr *reservation.ClosedError
+		switch {
+		case errors.As(err, &rentalErr), errors.As(err, &closedErr), errors.Is(err, errExtensionEndDate):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if extension.Amount == 0 {
+		c.JSON(http.StatusCreated, gin.H{"extension": extension})
+		return
+	}
+
+	var user models.User
+	h.DB.First(&user, "id = ?", userID)
+
+	transaction, err := h.Tripay.CreateTransaction(c.Request.Context(), tripay.TransactionRequest{
+		Method:        payload.PaymentMethod,
+		MerchantRef:   extension.ID.String(),
+		Amount:        extension.Amount,
+		CustomerName:  user.Name,
+		CustomerEmail: user.Email,
+		CustomerPhone: user.Telepon,
+		OrderItems:    tripayItems,
+	})
+	if err != nil {
+		h.cancelExtension(extension.ID, "failed to create Tripay transaction")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create transaction with Tripay", "details": err.Error()})
+		return
+	}
+
+	payment := tripay.NewPayment(order.ID, transaction)
+	payment.Purpose = tripay.PurposeExtension
+	extension.Reference = transaction.Reference
+	if payment.ExpiredAt != nil {
+		extension.ExpiresAt = payment.ExpiredAt
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		return tx.Model(&extension).Updates(map[string]interface{}{"reference": extension.Reference, "expires_at": extension.ExpiresAt}).Error
+	})
+	if err != nil {
+		h.cancelExtension(extension.ID, "failed to save Tripay payment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save payment, the extension has been cancelled", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"extension": extension, "transaction": transaction})
+}
+
+// cancelExtension membatalkan perpanjangan pending yang gagal ditagih. Jika gagal,
+// scheduler tetap mengakhirinya setelah expires_at lewat.
+func (h *Handler) cancelExtension(extensionID uuid.UUID, reason string) {
+	err := h.DB.Model(&models.OrderExtension{}).
+		Where("id = ? AND status = ?", extensionID, reservation.ExtensionPending).
+		Update("status", reservation.ExtensionCancelled).Error
+	if err != nil {
+		log.Printf("Failed to cancel extension %s (%s): %v", extensionID, reason, err)
+	}
+}
+
+// GetExtensions menampilkan riwayat perpanjangan sebuah order.
+// Bisa diakses oleh penyewa pemilik order maupun toko yang menerima order.
+func (h *Handler) GetExtensions(c *gin.Context) {
+	userIDInterface, _ := c.Get("userID")
+	userIDString, _ := userIDInterface.(string)
+
+	var order models.Order
+	if err := h.DB.Preload("Shop").First(&order, "id = ?", c.Param("orderId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if order.UserID.String() != userIDString && order.Shop.UserID.String() != userIDString {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to view this order"})
+		return
+	}
+
+	var extensions []models.OrderExtension
+	h.DB.Where("order_id = ?", order.ID).Order("created_at ASC").Find(&extensions)
+	if extensions == nil {
+		extensions = make([]models.OrderExtension, 0)
+	}
+	c.JSON(http.StatusOK, extensions)
+}
@@ -0,0 +1,184 @@
+// Lokasi: internal/order/fulfillment.go
+package order
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"sewascaf.com/api/internal/geo"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/tripay"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Cara barang diserahkan ke penyewa
+const (
+	FulfillmentPickup   = "pickup"
+	FulfillmentDelivery = "delivery"
+)
+
+// Jenis aturan ongkos kirim toko
+const (
+	DeliveryFeeFlat = "flat"
+	DeliveryFeeZone = "zone"
+)
+
+// FulfillmentError adalah pilihan pengiriman yang tidak valid untuk toko tujuan
+type FulfillmentError struct {
+	Message string
+}
+
+func (e *FulfillmentError) Error() string { return e.Message }
+
+// FulfillmentPayload adalah pilihan pengambilan atau pengiriman di body order.
+// Window adalah rentang jam barang diambil atau diantar di tanggal mulai sewa (RFC3339).
+type FulfillmentPayload struct {
+	FulfillmentMethod string   `json:"fulfillment_method" binding:"omitempty,oneof=pickup delivery"`
+	DeliveryAddress   string   `json:"delivery_address"`
+	DeliveryNotes     string   `json:"delivery_notes"`
+	DeliveryZoneID    string   `json:"delivery_zone_id"`
+	DeliveryLatitude  *float64 `json:"delivery_latitude"`
+	DeliveryLongitude *float64 `json:"delivery_longitude"`
+	WindowStart       string   `json:"window_start"`
+	WindowEnd         string   `json:"window_end"`
+}
+
+// Fulfillment adalah FulfillmentPayload yang sudah divalidasi
+type Fulfillment struct {
+	Method      string
+	Address     string
+	Notes       string
+	ZoneID      *uuid.UUID
+	Location    *geo.Point
+	WindowStart *time.Time
+	WindowEnd   *time.Time
+}
+
+// ParseFulfillment memvalidasi format pilihan pengiriman. Tanpa fulfillment_method
+// order dianggap diambil sendiri di toko.
+func ParseFulfillment(payload FulfillmentPayload, startDate time.Time) (Fulfillment, error) {
+	f := Fulfillment{Method: payload.FulfillmentMethod, Notes: payload.DeliveryNotes}
+	if f.Method == "" {
+		f.Method = FulfillmentPickup
+	}
+
+	if payload.WindowStart != "" || payload.WindowEnd != "" {
+		windowStart, err := time.Parse(time.RFC3339, payload.WindowStart)
+		if err != nil {
+			return f, errors.New("invalid window_start format, use RFC3339")
+		}
+		windowEnd, err := time.Parse(time.RFC3339, payload.WindowEnd)
+		if err != nil {
+			return f, errors.New("invalid window_end format, use RFC3339")
+		}
+		if !windowEnd.After(windowStart) {
+			return f, errors.New("window_end must be after window_start")
+		}
+		if windowStart.Format("2006-01-02") != startDate.Format("2006-01-02") {
+			return f, errors.New("the pickup or delivery window must be on the start_date")
+		}
+		f.WindowStart = &windowStart
+		f.WindowEnd = &windowEnd
+	}
+
+	if f.Method == FulfillmentDelivery {
+		if payload.DeliveryAddress == "" {
+			return f, errors.New("delivery_address is required for delivery")
+		}
+		if f.WindowStart == nil {
+			return f, errors.New("window_start and window_end are required for delivery")
+		}
+		f.Address = payload.DeliveryAddress
+		if payload.DeliveryZoneID != "" {
+			zoneID, err := uuid.Parse(payload.DeliveryZoneID)
+			if err != nil {
+				return f, errors.New("invalid delivery_zone_id")
+			}
+			f.ZoneID = &zoneID
+		}
+		if payload.DeliveryLatitude != nil || payload.DeliveryLongitude != nil {
+			if payload.DeliveryLatitude == nil || payload.DeliveryLongitude == nil {
+				return f, errors.New("delivery_latitude and delivery_longitude must be provided together")
+			}
+			location := geo.Point{Lat: *payload.DeliveryLatitude, Lng: *payload.DeliveryLongitude}
+			if !location.Valid() {
+				return f, errors.New("delivery_latitude or delivery_longitude is out of range")
+			}
+			f.Location = &location
+		}
+	}
+	return f, nil
+}
+
+// applyFulfillment mencatat pilihan pengiriman di draft dan menambahkan ongkos kirim
+// ke TotalPrice serta item transaksi Tripay sesuai aturan ongkos kirim toko.
+// Untuk ongkos kirim per zona, zona dipilih otomatis dari jarak toko ke lokasi
+// pengiriman jika penyewa tidak memilih delivery_zone_id.
+func applyFulfillment(tx *gorm.DB, draft *Draft, f Fulfillment) error {
+	draft.Order.FulfillmentMethod = f.Method
+	draft.Order.FulfillmentWindowStart = f.WindowStart
+	draft.Order.FulfillmentWindowEnd = f.WindowEnd
+	draft.Order.DeliveryNotes = f.Notes
+	if f.Method != FulfillmentDelivery {
+		return nil
+	}
+
+	var shop models.Shop
+	if err := tx.First(&shop, "id = ?", draft.Order.ShopID).Error; err != nil {
+		return err
+	}
+	if !shop.DeliveryEnabled {
+		return &FulfillmentError{Message: "shop " + shop.ShopName + " does not offer delivery"}
+	}
+
+	var distance *float64
+	if f.Location != nil {
+		draft.Order.DeliveryLatitude = &f.Location.Lat
+		draft.Order.DeliveryLongitude = &f.Location.Lng
+		if shop.Latitude != nil && shop.Longitude != nil {
+			km := math.Round(geo.DistanceKm(geo.Point{Lat: *shop.Latitude, Lng: *shop.Longitude}, *f.Location)*10) / 10
+			distance = &km
+			draft.Order.DeliveryDistanceKm = distance
+		}
+	}
+
+	fee := Fee{Code: FeeDelivery, Description: "Delivery fee"}
+	switch shop.DeliveryFeeType {
+	case DeliveryFeeZone:
+		var zone models.DeliveryZone
+		if f.ZoneID != nil {
+			if err := tx.First(&zone, "id = ? AND shop_id = ?", *f.ZoneID, shop.ID).Error; err != nil {
+				return &FulfillmentError{Message: "delivery zone not found for shop " + shop.ShopName}
+			}
+			if distance != nil && *distance > zone.MaxDistanceKm {
+				return &FulfillmentError{Message: "delivery address is outside the selected delivery zone " + zone.Name}
+			}
+		} else {
+			if distance == nil {
+				return &FulfillmentError{Message: "delivery_zone_id or delivery coordinates are required for delivery from shop " + shop.ShopName}
+			}
+			// Zona terkecil yang masih mencakup jarak pengiriman
+			err := tx.Where("shop_id = ? AND max_distance_km >= ?", shop.ID, *distance).Order("max_distance_km ASC").First(&zone).Error
+			if err != nil {
+				return &FulfillmentError{Message: "delivery address is outside the delivery area of shop " + shop.ShopName}
+			}
+		}
+		draft.Order.DeliveryZoneID = &zone.ID
+		fee.Description = "Delivery fee (" + zone.Name + ")"
+		fee.Amount = zone.Fee
+	default:
+		fee.Amount = shop.DeliveryFlatFee
+	}
+
+	draft.Order.DeliveryAddress = f.Address
+	draft.Order.DeliveryFee = fee.Amount
+	if fee.Amount > 0 {
+		draft.Order.TotalPrice += fee.Amount
+		draft.Fees = append(draft.Fees, fee)
+		draft.TripayItems = append(draft.TripayItems, tripay.OrderItem{SKU: "DELIVERY", Name: fee.Description, Price: fee.Amount, Quantity: 1})
+	}
+	return nil
+}
@@ -1,36 +1,38 @@
 package order
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
-	"strconv"
-	"strings"
 	"time"
 
+	"sewascaf.com/api/internal/lifecycle"
 	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/pricing"
+	"sewascaf.com/api/internal/refund"
+	"sewascaf.com/api/internal/reservation"
+	"sewascaf.com/api/internal/tripay"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Handler struct {
-	DB                 *gorm.DB
-	TripayAPIKey       string
-	TripayPrivateKey   string
-	TripayMerchantCode string
+	DB      *gorm.DB
+	Tripay  tripay.Client
+	Refunds refund.Provider
+	// PaymentTTL adalah batas pembayaran perpanjangan sebelum Tripay memberi expired_time
+	PaymentTTL time.Duration
 }
 
-func NewHandler(db *gorm.DB, apiKey, privateKey, merchantCode string) *Handler {
+func NewHandler(db *gorm.DB, tripayClient tripay.Client, refundProvider refund.Provider, paymentTTL time.Duration) *Handler {
 	return &Handler{
-		DB:                 db,
-		TripayAPIKey:       apiKey,
-		TripayPrivateKey:   privateKey,
-		TripayMerchantCode: merchantCode,
+		DB:         db,
+		Tripay:     tripayClient,
+		Refunds:    refundProvider,
+		PaymentTTL: paymentTTL,
 	}
 }
 
@@ -45,136 +47,270 @@ type CreateOrderPayload struct {
 	EndDate       string             `json:"end_date" binding:"required"`
 	PaymentMethod string             `json:"payment_method" binding:"required"`
 	Items         []OrderItemPayload `json:"items" binding:"required,min=1"`
+	FulfillmentPayload
 }
 
-type TripayResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data"`
+// orderRequest adalah CreateOrderPayload yang sudah divalidasi
+type orderRequest struct {
+	UserID      uuid.UUID
+	Shop        models.Shop
+	StartDate   time.Time
+	EndDate     time.Time
+	Items       []ItemRequest
+	Fulfillment Fulfillment
 }
 
-func (h *Handler) CreateOrder(c *gin.Context) {
+// parseOrderRequest memvalidasi payload order dan toko tujuannya.
+// Jika gagal, respons error sudah dikirim dan ok bernilai false.
+func (h *Handler) parseOrderRequest(c *gin.Context, payload CreateOrderPayload) (*orderRequest, bool) {
 	userIDInterface, _ := c.Get("userID")
 	userIDString, _ := userIDInterface.(string)
-
-	var payload CreateOrderPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
-		return
+	userID, err := uuid.Parse(userIDString)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return nil, false
 	}
 
 	startDate, err := time.Parse("2006-01-02", payload.StartDate)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format, use YYYY-MM-DD"})
-		return
+		return nil, false
 	}
 	endDate, err := time.Parse("2006-01-02", payload.EndDate)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format, use YYYY-MM-DD"})
-		return
+		return nil, false
 	}
 
-	var totalOrderPrice int = 0
-	var newOrderItems []models.OrderItem
-	var orderProducts []map[string]interface{}
-	var newOrder models.Order
+	fulfillment, err := ParseFulfillment(payload.FulfillmentPayload, startDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
 
-	err = h.DB.Transaction(func(tx *gorm.DB) error {
-		for _, item := range payload.Items {
-			var product models.Product
-			if err := tx.First(&product, "id = ?", item.ProductID).Error; err != nil {
-				return errors.New("product with id " + item.ProductID + " not found")
-			}
-			var rentedQuantity int64
-			tx.Model(&models.OrderItem{}).Joins("JOIN orders ON orders.id = order_items.order_id").Where(`order_items.product_id = ? AND orders.status IN ('active', 'pending') AND (orders.start_date, orders.end_date) OVERLAPS (?, ?)`, item.ProductID, startDate, endDate).Select("COALESCE(SUM(order_items.quantity), 0)").Scan(&rentedQuantity)
-			availableStock := int64(product.Stock) - rentedQuantity
-			if availableStock < int64(item.Quantity) {
-				return errors.New("stock for product " + product.Name + " is not available on the selected dates")
-			}
-			var effectivePrice int
-			if product.DiscountPricePerDay > 0 {
-				effectivePrice = product.DiscountPricePerDay
-			} else {
-				effectivePrice = product.PricePerDay
-			}
-			durationDays := int(endDate.Sub(startDate).Hours() / 24)
-			if durationDays < 1 {
-				durationDays = 1
-			}
-			itemTotalPriceForDuration := effectivePrice * durationDays
-			subTotal := item.Quantity * itemTotalPriceForDuration
-			totalOrderPrice += subTotal
-			newOrderItems = append(newOrderItems, models.OrderItem{ID: uuid.New(), ProductID: product.ID, Quantity: item.Quantity, PriceAtTimeOfOrder: effectivePrice})
-			orderProducts = append(orderProducts, map[string]interface{}{"sku": product.SKU, "name": product.Name, "price": itemTotalPriceForDuration, "quantity": item.Quantity})
-		}
+	var shop models.Shop
+	if err := h.DB.Where("id = ?", payload.ShopID).First(&shop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return nil, false
+	}
+	if !shop.ActivePaymentChannels.Contains(payload.PaymentMethod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment method " + payload.PaymentMethod + " is not accepted by this shop"})
+		return nil, false
+	}
 
-		newOrder = models.Order{
-			ID:            uuid.New(),
-			UserID:        uuid.MustParse(userIDString),
-			ShopID:        uuid.MustParse(payload.ShopID),
-			TotalPrice:    totalOrderPrice,
-			Status:        "pending",
-			StartDate:     startDate,
-			EndDate:       endDate,
-			PaymentMethod: payload.PaymentMethod,
+	var items []ItemRequest
+	for _, item := range payload.Items {
+		productID, err := uuid.Parse(item.ProductID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product with id " + item.ProductID + " not found"})
+			return nil, false
 		}
+		items = append(items, ItemRequest{ProductID: productID, Quantity: item.Quantity})
+	}
 
-		if err := tx.Create(&newOrder).Error; err != nil {
-			return err
-		}
-		for i := range newOrderItems {
-			newOrderItems[i].OrderID = newOrder.ID
-		}
-		if err := tx.Create(&newOrderItems).Error; err != nil {
-			return err
-		}
-		return nil
+	return &orderRequest{
+		UserID:      userID,
+		Shop:        shop,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Items:       items,
+		Fulfillment: fulfillment,
+	}, true
+}
+
+func (h *Handler) CreateOrder(c *gin.Context) {
+	var payload CreateOrderPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	req, ok := h.parseOrderRequest(c, payload)
+	if !ok {
+		return
+	}
+
+	var placed *Draft
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		placed, err = PlaceOrder(tx, req.UserID, req.Shop.ID, req.StartDate, req.EndDate, payload.PaymentMethod, req.Items, req.Fulfillment)
+		return err
 	})
 
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		respondPlaceError(c, err)
 		return
 	}
 
 	var user models.User
-	h.DB.First(&user, "id = ?", userIDString)
-	merchantRef := newOrder.ID.String()
-	amount := newOrder.TotalPrice
-	mac := hmac.New(sha256.New, []byte(h.TripayPrivateKey))
-	mac.Write([]byte(h.TripayMerchantCode + merchantRef + strconv.Itoa(amount)))
-	signature := hex.EncodeToString(mac.Sum(nil))
-
-	tripayPayload := map[string]interface{}{
-		"method":         payload.PaymentMethod,
-		"merchant_ref":   merchantRef,
-		"amount":         amount,
-		"customer_name":  user.Name,
-		"customer_email": user.Email,
-		"order_items":    orderProducts,
-		"signature":      signature,
-	}
-
-	payloadBytes, _ := json.Marshal(tripayPayload)
-	req, _ := http.NewRequest("POST", "https://tripay.co.id/api-sandbox/transaction/create", strings.NewReader(string(payloadBytes)))
-	req.Header.Set("Authorization", "Bearer "+h.TripayAPIKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	h.DB.First(&user, "id = ?", req.UserID)
+
+	transaction, err := ChargeOrders(c.Request.Context(), h.DB, h.Tripay, user, placed.Order.ID.String(), payload.PaymentMethod, []*Draft{placed})
+	if errors.Is(err, ErrPaymentNotSaved) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save payment, the order has been cancelled", "details": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction with Tripay"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction with Tripay", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transaction)
+}
+
+// respondPlaceError mengirim error pembuatan order: pilihan pengiriman yang tidak
+// valid, tanggal di hari libur toko, atau durasi sewa di luar batas produk menjadi
+// 400, sisanya (stok habis, produk toko lain) menjadi 409
+func respondPlaceError(c *gin.Context, err error) {
+	if IsRequestError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+}
+
+// IsRequestError melaporkan apakah err dari BuildOrder/PlaceOrder disebabkan isi
+// request (pengiriman, hari libur toko, durasi sewa), bukan kondisi stok
+func IsRequestError(err error) bool {
+	var fulfillmentErr *FulfillmentError
+	var closedErr *reservation.ClosedError
+	var rentalErr *pricing.RentalError
+	return errors.As(err, &fulfillmentErr) || errors.As(err, &closedErr) || errors.As(err, &rentalErr)
+}
+
+type OrderItemDetail struct {
+	ID                 uuid.UUID      `json:"id"`
+	ProductID          uuid.UUID      `json:"product_id"`
+	ProductName        string         `json:"product_name"`
+	ProductImageURL    string         `json:"product_image_url"`
+	Quantity           int            `json:"quantity"`
+	PriceAtTimeOfOrder int            `json:"price_at_time_of_order"`
+	RentalDays         int            `json:"rental_days"`
+	Subtotal           int            `json:"subtotal"`
+	PriceBreakdown     models.JSONRaw `json:"price_breakdown"`
+	DepositPerUnit     int            `json:"deposit_per_unit"`
+}
+
+type FulfillmentDetail struct {
+	Method          string     `json:"method"`
+	DeliveryAddress string     `json:"delivery_address"`
+	DeliveryNotes   string     `json:"delivery_notes"`
+	DeliveryZoneID  *uuid.UUID `json:"delivery_zone_id"`
+	DeliveryFee     int        `json:"delivery_fee"`
+	DistanceKm      *float64   `json:"distance_km"`
+	WindowStart     *time.Time `json:"window_start"`
+	WindowEnd       *time.Time `json:"window_end"`
+}
+
+type OrderDetailResponse struct {
+	ID            uuid.UUID                   `json:"id"`
+	UserID        uuid.UUID                   `json:"user_id"`
+	Shop          ShopSummaryForOrder         `json:"shop"`
+	TotalPrice    int                         `json:"total_price"`
+	DepositAmount int                         `json:"deposit_amount"`
+	OverdueAt     *time.Time                  `json:"overdue_at"`
+	LateDays      int                         `json:"late_days"`
+	LateFee       int                         `json:"late_fee"`
+	Status        string                      `json:"status"`
+	StartDate     time.Time                   `json:"start_date"`
+	EndDate       time.Time                   `json:"end_date"`
+	CreatedAt     time.Time                   `json:"created_at"`
+	PaymentMethod string                      `json:"payment_method"`
+	Fulfillment   FulfillmentDetail           `json:"fulfillment"`
+	Items         []OrderItemDetail           `json:"items"`
+	Payment       *models.Payment             `json:"payment"`
+	ExtraPayments []models.Payment            `json:"extra_payments"`
+	Timeline      []models.OrderStatusHistory `json:"timeline"`
+}
+
+// GetOrderDetail menampilkan detail order beserta item dan info pembayaran Tripay.
+// Bisa diakses oleh penyewa pemilik order maupun toko yang menerima order.
+func (h *Handler) GetOrderDetail(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+	userIDString, ok := userIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
 		return
 	}
-	defer resp.Body.Close()
+	orderID := c.Param("orderId")
+
+	var order models.Order
+	if err := h.DB.Preload("Shop").Preload("OrderItems.Product").First(&order, "id = ?", orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	if order.UserID.String() != userIDString && order.Shop.UserID.String() != userIDString {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to view this order"})
+		return
+	}
+
+	response := OrderDetailResponse{
+		ID:     order.ID,
+		UserID: order.UserID,
+		Shop: ShopSummaryForOrder{
+			ShopName:            order.Shop.ShopName,
+			ShopAddress:         order.Shop.ShopAddress,
+			ShopPhoneNumber:     order.Shop.ShopPhoneNumber,
+			ShopProfileImageURL: order.Shop.ShopProfileImageURL,
+		},
+		TotalPrice:    order.TotalPrice,
+		DepositAmount: order.DepositAmount,
+		OverdueAt:     order.OverdueAt,
+		LateDays:      order.LateDays,
+		LateFee:       order.LateFee,
+		Status:        order.Status,
+		StartDate:     order.StartDate,
+		EndDate:       order.EndDate,
+		CreatedAt:     order.CreatedAt,
+		PaymentMethod: order.PaymentMethod,
+		Fulfillment: FulfillmentDetail{
+			Method:          order.FulfillmentMethod,
+			DeliveryAddress: order.DeliveryAddress,
+			DeliveryNotes:   order.DeliveryNotes,
+			DeliveryZoneID:  order.DeliveryZoneID,
+			DeliveryFee:     order.DeliveryFee,
+			DistanceKm:      order.DeliveryDistanceKm,
+			WindowStart:     order.FulfillmentWindowStart,
+			WindowEnd:       order.FulfillmentWindowEnd,
+		},
+		Items: make([]OrderItemDetail, 0, len(order.OrderItems)),
+	}
+	for _, item := range order.OrderItems {
+		response.Items = append(response.Items, OrderItemDetail{
+			ID:                 item.ID,
+			ProductID:          item.ProductID,
+			ProductName:        item.Product.Name,
+			ProductImageURL:    item.Product.ImageURL,
+			Quantity:           item.Quantity,
+			PriceAtTimeOfOrder: item.PriceAtTimeOfOrder,
+			RentalDays:         item.RentalDays,
+			Subtotal:           item.Subtotal,
+			PriceBreakdown:     item.PriceBreakdown,
+			DepositPerUnit:     item.DepositPerUnit,
+		})
+	}
 
-	var tripayResponse TripayResponse
-	json.NewDecoder(resp.Body).Decode(&tripayResponse)
-	if !tripayResponse.Success {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tripay returned an error", "details": tripayResponse.Message})
+	var payment models.Payment
+	if err := h.DB.Where("order_id = ? AND purpose = ?", order.ID, tripay.PurposeOrder).Order("created_at DESC").First(&payment).Error; err == nil {
+		response.Payment = &payment
+	}
+	// Tagihan tambahan seperti denda keterlambatan ditampilkan terpisah dari pembayaran order
+	response.ExtraPayments = make([]models.Payment, 0)
+	h.DB.Where("order_id = ? AND purpose <> ?", order.ID, tripay.PurposeOrder).Order("created_at ASC").Find(&response.ExtraPayments)
+
+	timeline, err := lifecycle.Timeline(h.DB, order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order timeline"})
 		return
 	}
+	response.Timeline = timeline
 
-	c.JSON(http.StatusCreated, tripayResponse.Data)
+	c.JSON(http.StatusOK, response)
 }
 
 type ShopSummaryForOrder struct {
@@ -298,32 +434,87 @@ func (h *Handler) GetUserOrders(c *gin.Context) {
 	c.JSON(http.StatusOK, finalResponse)
 }
 
+var errCancelNotOwned = errors.New("order not found or you do not have permission to cancel it")
+
+// CancelOrder membatalkan order milik penyewa. Order pending langsung dibatalkan,
+// sedangkan order yang sudah dibayar dibatalkan sesuai kebijakan pembatalan toko
+// dan dana yang bisa dikembalikan diproses lewat refund provider.
 func (h *Handler) CancelOrder(c *gin.Context) {
 	userIDInterface, _ := c.Get("userID")
 	userIDString, _ := userIDInterface.(string)
 	orderID := c.Param("orderId")
 
+	var pendingRefund *models.Refund
 	err := h.DB.Transaction(func(tx *gorm.DB) error {
 		var order models.Order
-		if err := tx.Where("id = ? AND user_id = ?", orderID, userIDString).First(&order).Error; err != nil {
-			return errors.New("order not found or you do not have permission to cancel it")
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND user_id = ?", orderID, userIDString).First(&order).Error; err != nil {
+			return errCancelNotOwned
 		}
 
-		if order.Status != "pending" {
-			return errors.New("only pending orders can be cancelled")
+		if order.Status == lifecycle.StatusPending {
+			return lifecycle.Transition(tx, &order, lifecycle.StatusCancelled, lifecycle.ActorRenter, &order.UserID, "cancelled by renter")
 		}
 
-		if err := tx.Model(&order).Update("status", "cancelled").Error; err != nil {
+		var shop models.Shop
+		if err := tx.First(&shop, "id = ?", order.ShopID).Error; err != nil {
 			return err
 		}
-
-		return nil
+		decision, err := refund.Evaluate(order, shop, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := lifecycle.Transition(tx, &order, lifecycle.StatusCancelled, lifecycle.ActorRenter, &order.UserID, "cancelled by renter after payment"); err != nil {
+			return err
+		}
+		pendingRefund, err = refund.Create(tx, order, decision, "cancelled by renter", h.Refunds.Name())
+		return err
 	})
 
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, refund.ErrAfterPickup), errors.Is(err, refund.ErrNotPaid), errors.Is(err, lifecycle.ErrStatusChanged), errors.Is(err, lifecycle.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, refund.ErrNoPaidPayment), errors.Is(err, refund.ErrAlreadyRefunds):
+			c.JSON(http.StatusConflict, gin.H{"error": "Order cannot be cancelled because its refund could not be recorded", "details": err.Error()})
+		case errors.Is(err, errCancelNotOwned), errors.Is(err, lifecycle.ErrActorNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order", "details": err.Error()})
+		}
+		return
+	}
+
+	if pendingRefund == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Order has been successfully cancelled"})
 		return
 	}
+	if err := refund.Execute(c.Request.Context(), h.DB, h.Refunds, pendingRefund); err != nil {
+		log.Printf("Failed to process refund %s for order %s: %v", pendingRefund.ID, pendingRefund.OrderID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Order has been successfully cancelled", "refund": pendingRefund})
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order has been successfully cancelled"})
-}
\ No newline at end of file
+// GetCancellationQuote menghitung dana yang akan dikembalikan jika penyewa
+// membatalkan order yang sudah dibayar saat ini, tanpa membatalkannya
+func (h *Handler) GetCancellationQuote(c *gin.Context) {
+	userIDInterface, _ := c.Get("userID")
+	userIDString, _ := userIDInterface.(string)
+
+	var order models.Order
+	if err := h.DB.Preload("Shop").Where("id = ? AND user_id = ?", c.Param("orderId"), userIDString).First(&order).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	decision, err := refund.Evaluate(order, order.Shop, time.Now())
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "refund": decision})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"order_id":                            order.ID,
+		"refund":                              decision,
+		"cancellation_full_refund_days":       order.Shop.CancellationFullRefundDays,
+		"cancellation_partial_refund_percent": order.Shop.CancellationPartialRefundPercent,
+	})
+}
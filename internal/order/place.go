@@ -0,0 +1,267 @@
+// Lokasi: internal/order/place.go
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/pricing"
+	"sewascaf.com/api/internal/reservation"
+	"sewascaf.com/api/internal/tripay"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ItemRequest adalah satu produk yang ingin disewa beserta jumlahnya
+type ItemRequest struct {
+	ProductID uuid.UUID
+	Quantity  int
+}
+
+// ItemQuote adalah rincian harga satu item order
+type ItemQuote struct {
+	ProductID   uuid.UUID `json:"product_id"`
+	ProductName string    `json:"product_name"`
+	pricing.Quote
+}
+
+// Kode biaya tambahan order
+const (
+	FeeDeposit  = "deposit"
+	FeeDelivery = "delivery"
+)
+
+// Fee adalah biaya tambahan di luar harga sewa produk
+type Fee struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Amount      int    `json:"amount"`
+}
+
+// Draft adalah order beserta item, rincian harga, biaya tambahan, dan item untuk transaksi Tripay
+type Draft struct {
+	Order       models.Order
+	Items       []models.OrderItem
+	Quotes      []ItemQuote
+	Fees        []Fee
+	TripayItems []tripay.OrderItem
+}
+
+// BuildOrder mengecek kepemilikan, stok, dan menghitung harga order tanpa menyimpannya.
+// Jika lock bernilai true, baris produk dikunci sampai transaksi tx selesai.
+func BuildOrder(tx *gorm.DB, userID, shopID uuid.UUID, startDate, endDate time.Time, paymentMethod string, items []ItemRequest, fulfillment Fulfillment, lock bool) (*Draft, error) {
+	if endDate.Before(startDate) {
+		return nil, errors.New("end_date must not be before start_date")
+	}
+	// Barang diambil dan dikembalikan di hari toko buka
+	if err := reservation.CheckShopOpen(tx, shopID, startDate, endDate); err != nil {
+		return nil, err
+	}
+
+	requested := make(map[uuid.UUID]int)
+	var productIDs []uuid.UUID
+	for _, item := range items {
+		requested[item.ProductID] += item.Quantity
+		productIDs = append(productIDs, item.ProductID)
+	}
+	loadProducts := reservation.LoadProducts
+	if lock {
+		loadProducts = reservation.LockProducts
+	}
+	products, err := loadProducts(tx, productIDs)
+	if err != nil {
+		if errors.Is(err, reservation.ErrProductNotFound) {
+			return nil, errors.New("one or more products were not found")
+		}
+		return nil, err
+	}
+	for productID, quantity := range requested {
+		product := products[productID]
+		if product.ShopID != shopID {
+			return nil, errors.New("product " + product.Name + " does not belong to the selected shop")
+		}
+		availableStock, err := reservation.Available(tx, product, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		if availableStock < quantity {
+			return nil, errors.New("stock for product " + product.Name + " is not available on the selected dates")
+		}
+	}
+
+	seasons, err := pricing.LoadSeasons(tx, productIDs, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	draft := &Draft{
+		Order: models.Order{
+			ID:            uuid.New(),
+			UserID:        userID,
+			ShopID:        shopID,
+			Status:        lifecycle.StatusPending,
+			StartDate:     startDate,
+			EndDate:       endDate,
+			PaymentMethod: paymentMethod,
+		},
+	}
+	for _, item := range items {
+		product := products[item.ProductID]
+		quote, err := pricing.QuoteFor(product, seasons[product.ID], startDate, endDate, item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		breakdown, err := json.Marshal(quote.Breakdown)
+		if err != nil {
+			return nil, err
+		}
+		draft.Order.TotalPrice += quote.Subtotal
+		draft.Items = append(draft.Items, models.OrderItem{
+			ID:                 uuid.New(),
+			OrderID:            draft.Order.ID,
+			ProductID:          product.ID,
+			Quantity:           item.Quantity,
+			PriceAtTimeOfOrder: pricing.DailyRate(product),
+			RentalDays:         quote.Days,
+			Subtotal:           quote.Subtotal,
+			PriceBreakdown:     models.JSONRaw(breakdown),
+			DepositPerUnit:     product.DepositAmount,
+		})
+		draft.Quotes = append(draft.Quotes, ItemQuote{ProductID: product.ID, ProductName: product.Name, Quote: quote})
+		draft.TripayItems = append(draft.TripayItems, tripay.OrderItem{SKU: product.SKU, Name: product.Name, Price: quote.UnitTotal, Quantity: item.Quantity})
+	}
+
+	// Deposit ditagih sebagai baris terpisah agar tidak tercampur dengan biaya sewa
+	for _, item := range items {
+		product := products[item.ProductID]
+		if product.DepositAmount <= 0 {
+			continue
+		}
+		draft.Order.DepositAmount += product.DepositAmount * item.Quantity
+		draft.TripayItems = append(draft.TripayItems, tripay.OrderItem{SKU: product.SKU + "-DEPOSIT", Name: "Deposit " + product.Name, Price: product.DepositAmount, Quantity: item.Quantity})
+	}
+	if draft.Order.DepositAmount > 0 {
+		draft.Fees = append(draft.Fees, Fee{Code: FeeDeposit, Description: "Refundable security deposit", Amount: draft.Order.DepositAmount})
+	}
+
+	if err := applyFulfillment(tx, draft, fulfillment); err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// PlaceOrder mengunci produk, mengecek stok, lalu membuat order pending
+// beserta item-nya di dalam transaksi tx. Semua produk harus milik shopID.
+func PlaceOrder(tx *gorm.DB, userID, shopID uuid.UUID, startDate, endDate time.Time, paymentMethod string, items []ItemRequest, fulfillment Fulfillment) (*Draft, error) {
+	// Kunci semua produk yang dipesan agar pengecekan stok dan pembuatan
+	// order terjadi secara atomik terhadap request lain
+	draft, err := BuildOrder(tx, userID, shopID, startDate, endDate, paymentMethod, items, fulfillment, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&draft.Order).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&draft.Items).Error; err != nil {
+		return nil, err
+	}
+	if err := lifecycle.RecordCreated(tx, &draft.Order, lifecycle.ActorRenter, &draft.Order.UserID); err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// ErrPaymentNotSaved berarti transaksi Tripay sudah dibuat tetapi Payment-nya
+// gagal disimpan; order yang bersangkutan sudah dibatalkan
+var ErrPaymentNotSaved = errors.New("failed to save Tripay payment")
+
+const savePaymentAttempts = 3
+
+// ChargeOrders membuat satu transaksi Tripay untuk satu atau beberapa order
+// dan menyimpan satu Payment per order dengan reference yang sama. Biaya channel
+// dicatat di Payment order pertama. Jika Tripay gagal atau Payment tidak bisa
+// disimpan, order dibatalkan agar stoknya tidak tertahan.
+func ChargeOrders(ctx context.Context, db *gorm.DB, client tripay.Client, user models.User, merchantRef, paymentMethod string, placed []*Draft) (*tripay.Transaction, error) {
+	amount := 0
+	var items []tripay.OrderItem
+	for _, p := range placed {
+		amount += p.Order.ChargeAmount()
+		items = append(items, p.TripayItems...)
+	}
+
+	transaction, err := client.CreateTransaction(ctx, tripay.TransactionRequest{
+		Method:        paymentMethod,
+		MerchantRef:   merchantRef,
+		Amount:        amount,
+		CustomerName:  user.Name,
+		CustomerEmail: user.Email,
+		CustomerPhone: user.Telepon,
+		OrderItems:    items,
+	})
+	if err != nil {
+		cancelUnpaid(db, placed, "failed to create Tripay transaction")
+		return nil, err
+	}
+
+	payments := make([]models.Payment, 0, len(placed))
+	for i, p := range placed {
+		payment := tripay.NewPayment(p.Order.ID, transaction)
+		payment.Amount = p.Order.ChargeAmount()
+		if i > 0 {
+			payment.FeeCustomer = 0
+			payment.FeeMerchant = 0
+		}
+		payment.TotalAmount = payment.Amount + payment.FeeCustomer
+		payments = append(payments, payment)
+	}
+	// Tanpa baris Payment, callback untuk reference ini tidak bisa dicocokkan ke
+	// order mana pun, jadi order dibatalkan jika Payment tetap gagal disimpan
+	if err := savePayments(db, payments); err != nil {
+		cancelUnpaid(db, placed, "failed to save Tripay payment")
+		return nil, fmt.Errorf("%w: %v", ErrPaymentNotSaved, err)
+	}
+	return transaction, nil
+}
+
+// savePayments menyimpan semua Payment satu checkout sekaligus, dicoba ulang
+// beberapa kali untuk gangguan database sesaat
+func savePayments(db *gorm.DB, payments []models.Payment) error {
+	var err error
+	for attempt := 1; attempt <= savePaymentAttempts; attempt++ {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(&payments).Error
+		})
+		if err == nil {
+			return nil
+		}
+		log.Printf("Failed to save Tripay payments (attempt %d/%d): %v", attempt, savePaymentAttempts, err)
+		if attempt < savePaymentAttempts {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+	}
+	return err
+}
+
+// CancelUnpaid membatalkan order pending yang gagal dibuatkan transaksi pembayaran
+func CancelUnpaid(tx *gorm.DB, order *models.Order, reason string) error {
+	return lifecycle.Transition(tx, order, lifecycle.StatusCancelled, lifecycle.ActorSystem, nil, reason)
+}
+
+func cancelUnpaid(db *gorm.DB, placed []*Draft, reason string) {
+	for _, p := range placed {
+		order := p.Order
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return CancelUnpaid(tx, &order, reason)
+		})
+		if err != nil {
+			log.Printf("Failed to cancel order %s: %v", order.ID, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/pricing"
+	"sewascaf.com/api/internal/reservation"
+	"sewascaf.com/api/internal/testdb"
+
+	"gorm.io/gorm"
+)
+
+var pickup = Fulfillment{Method: FulfillmentPickup}
+
+// TestPlaceOrderParallelDoesNotOversell memesan unit terakhir dari banyak
+// goroutine sekaligus; LockProducts harus membuat hanya satu yang berhasil.
+func TestPlaceOrderParallelDoesNotOversell(t *testing.T) {
+	db := testdb.Open(t)
+	shop := testdb.Shop(t, db)
+	product := testdb.Product(t, db, shop, 2, 100000)
+	start, end := testdb.Date(7), testdb.Date(10)
+
+	const renters = 5
+	users := make([]models.User, renters)
+	for i := range users {
+		users[i] = testdb.User(t, db, models.RoleUser)
+	}
+
+	var wg sync.WaitGroup
+	ready := make(chan struct{})
+	results := make(chan error, renters)
+	for _, user := range users {
+		wg.Add(1)
+		go func(user models.User) {
+			defer wg.Done()
+			<-ready
+			results <- db.Transaction(func(tx *gorm.DB) error {
+				_, err := PlaceOrder(tx, user.ID, shop.ID, start, end, "BRIVA", []ItemRequest{{ProductID: product.ID, Quantity: 2}}, pickup)
+				return err
+			})
+		}(user)
+	}
+	close(ready)
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d orders succeeded, want exactly 1", succeeded)
+	}
+	available, err := reservation.Available(db, product, start, end)
+	testdb.Fatal(t, err, "Available")
+	if available != 0 {
+		t.Errorf("available = %d after the product was fully booked, want 0", available)
+	}
+}
+
+func TestPlaceOrderUsesPerDayPeak(t *testing.T) {
+	db := testdb.Open(t)
+	shop := testdb.Shop(t, db)
+	product := testdb.Product(t, db, shop, 1, 100000)
+	items := []ItemRequest{{ProductID: product.ID, Quantity: 1}}
+
+	place := func(start, end time.Time) error {
+		renter := testdb.User(t, db, models.RoleUser)
+		return db.Transaction(func(tx *gorm.DB) error {
+			_, err := PlaceOrder(tx, renter.ID, shop.ID, start, end, "BRIVA", items, pickup)
+			return err
+		})
+	}
+
+	tests := []struct {
+		name    string
+		start   time.Time
+		end     time.Time
+		wantErr bool
+	}{
+		{"first rental", testdb.Date(5), testdb.Date(7), false},
+		{"back to back rental reuses the returned unit", testdb.Date(7), testdb.Date(9), false},
+		{"overlapping rental is rejected", testdb.Date(6), testdb.Date(8), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := place(tt.start, tt.end)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PlaceOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsRequestError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rental days out of range", fmt.Errorf("quote: %w", &pricing.RentalError{Message: "too short"}), true},
+		{"invalid fulfillment", &FulfillmentError{Message: "delivery is not available"}, true},
+		{"shop closed", &reservation.ClosedError{Message: "shop is closed"}, true},
+		{"out of stock", errors.New("stock for product Scaffolding is not available on the selected dates"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRequestError(tt.err); got != tt.want {
+			t.Errorf("%s: IsRequestError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
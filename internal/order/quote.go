@@ -0,0 +1,76 @@
+// Lokasi: internal/order/quote.go
+package order
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type QuoteResponse struct {
+	ShopID        uuid.UUID   `json:"shop_id"`
+	StartDate     time.Time   `json:"start_date"`
+	EndDate       time.Time   `json:"end_date"`
+	DurationDays  int         `json:"duration_days"`
+	Items         []ItemQuote `json:"items"`
+	Subtotal      int         `json:"subtotal"`
+	Fees          []Fee       `json:"fees"`
+	TotalPrice    int         `json:"total_price"`
+	Deposit       int         `json:"deposit"`
+	PaymentMethod string      `json:"payment_method"`
+	ChannelFee    int         `json:"channel_fee"`
+	TotalPayable  int         `json:"total_payable"`
+}
+
+// QuoteOrder menghitung total yang akan ditagih untuk sebuah order tanpa menyimpan apa pun.
+// Logika stok dan harga sama persis dengan CreateOrder, ditambah biaya channel Tripay.
+func (h *Handler) QuoteOrder(c *gin.Context) {
+	var payload CreateOrderPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	req, ok := h.parseOrderRequest(c, payload)
+	if !ok {
+		return
+	}
+
+	draft, err := BuildOrder(h.DB, req.UserID, req.Shop.ID, req.StartDate, req.EndDate, payload.PaymentMethod, req.Items, req.Fulfillment, false)
+	if err != nil {
+		respondPlaceError(c, err)
+		return
+	}
+
+	fee, err := h.Tripay.CalculateFee(c.Request.Context(), payload.PaymentMethod, draft.Order.ChargeAmount())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to calculate payment fee with Tripay", "details": err.Error()})
+		return
+	}
+
+	response := QuoteResponse{
+		ShopID:        draft.Order.ShopID,
+		StartDate:     draft.Order.StartDate,
+		EndDate:       draft.Order.EndDate,
+		Items:         draft.Quotes,
+		Fees:          draft.Fees,
+		TotalPrice:    draft.Order.TotalPrice,
+		Deposit:       draft.Order.DepositAmount,
+		PaymentMethod: payload.PaymentMethod,
+		ChannelFee:    fee.TotalFee.Customer,
+		TotalPayable:  draft.Order.ChargeAmount() + fee.TotalFee.Customer,
+	}
+	for _, item := range draft.Quotes {
+		response.Subtotal += item.Subtotal
+		if item.Days > response.DurationDays {
+			response.DurationDays = item.Days
+		}
+	}
+	if response.Fees == nil {
+		response.Fees = make([]Fee, 0)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
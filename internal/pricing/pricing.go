@@ -0,0 +1,212 @@
+// Lokasi: internal/pricing/pricing.go
+package pricing
+
+import (
+	"fmt"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/reservation"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	daysPerWeek  = 7
+	daysPerMonth = 30
+)
+
+// Line adalah satu baris rincian harga sewa untuk satu unit produk
+type Line struct {
+	Description string `json:"description"`
+	Unit        string `json:"unit"`
+	Quantity    int    `json:"quantity"`
+	UnitPrice   int    `json:"unit_price"`
+	Amount      int    `json:"amount"`
+}
+
+// Breakdown adalah rincian harga sewa satu unit produk untuk seluruh periode
+type Breakdown struct {
+	Days      int    `json:"days"`
+	Lines     []Line `json:"lines"`
+	UnitTotal int    `json:"unit_total"`
+}
+
+// Quote adalah harga sewa produk untuk sejumlah unit
+type Quote struct {
+	Breakdown
+	Quantity int `json:"quantity"`
+	Subtotal int `json:"subtotal"`
+}
+
+// RentalError dikembalikan ketika durasi sewa tidak memenuhi aturan produk
+type RentalError struct {
+	Message string
+}
+
+func (e *RentalError) Error() string { return e.Message }
+
+// DailyRate adalah harga harian efektif produk (harga diskon jika ada)
+func DailyRate(product models.Product) int {
+	if product.DiscountPricePerDay > 0 {
+		return product.DiscountPricePerDay
+	}
+	return product.PricePerDay
+}
+
+// RentalDays menghitung jumlah hari sewa, minimal satu hari
+func RentalDays(startDate, endDate time.Time) int {
+	return len(reservation.Days(startDate, endDate))
+}
+
+// LoadSeasons mengambil harga musiman produk yang beririsan dengan periode sewa.
+// Periode sewa mengikuti reservation.Days, jadi endDate tidak ikut dihitung,
+// sedangkan tanggal akhir musim ikut dihitung.
+func LoadSeasons(db *gorm.DB, productIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID][]models.SeasonalPrice, error) {
+	days := reservation.Days(startDate, endDate)
+	var seasons []models.SeasonalPrice
+	err := db.Where("product_id IN ? AND start_date <= ? AND end_date >= ?", productIDs, days[len(days)-1], days[0]).
+		Order("start_date ASC").
+		Find(&seasons).Error
+	if err != nil {
+		return nil, err
+	}
+	byProduct := make(map[uuid.UUID][]models.SeasonalPrice)
+	for _, season := range seasons {
+		byProduct[season.ProductID] = append(byProduct[season.ProductID], season)
+	}
+	return byProduct, nil
+}
+
+// Price menghitung harga sewa satu unit produk. Hari yang jatuh di periode harga
+// musiman memakai harga harian musiman, sisa harinya memakai tarif bulanan,
+// mingguan, lalu harian. Sisa hari tidak pernah lebih mahal dari satu minggu
+// atau satu bulan penuh.
+func Price(product models.Product, seasons []models.SeasonalPrice, startDate, endDate time.Time) (Breakdown, error) {
+	days := reservation.Days(startDate, endDate)
+	if product.MinRentalDays > 0 && len(days) < product.MinRentalDays {
+		return Breakdown{}, &RentalError{Message: fmt.Sprintf("%s must be rented for at least %d days", product.Name, product.MinRentalDays)}
+	}
+	if product.MaxRentalDays > 0 && len(days) > product.MaxRentalDays {
+		return Breakdown{}, &RentalError{Message: fmt.Sprintf("%s can be rented for at most %d days", product.Name, product.MaxRentalDays)}
+	}
+	return price(product, seasons, days), nil
+}
+
+// ExtensionPrice menghitung harga satu unit untuk memperpanjang sewa dari oldEndDate
+// ke newEndDate, yaitu selisih harga periode startDate–newEndDate dengan periode
+// startDate–oldEndDate. Perpanjangan berulang hanya membayar hari tambahannya saja.
+// Aturan lama sewa hanya dicek untuk periode baru.
+func ExtensionPrice(product models.Product, seasons []models.SeasonalPrice, startDate, oldEndDate, newEndDate time.Time) (int, error) {
+	extended, err := Price(product, seasons, startDate, newEndDate)
+	if err != nil {
+		return 0, err
+	}
+	current := price(product, seasons, reservation.Days(startDate, oldEndDate))
+	if extended.UnitTotal < current.UnitTotal {
+		return 0, nil
+	}
+	return extended.UnitTotal - current.UnitTotal, nil
+}
+
+func price(product models.Product, seasons []models.SeasonalPrice, days []time.Time) Breakdown {
+	breakdown := Breakdown{Days: len(days)}
+	seasonalDays := make(map[uuid.UUID]int)
+	regularDays := 0
+	for _, day := range days {
+		if season, ok := seasonFor(seasons, day); ok {
+			seasonalDays[season.ID]++
+			continue
+		}
+		regularDays++
+	}
+
+	for _, season := range seasons {
+		count := seasonalDays[season.ID]
+		if count == 0 {
+			continue
+		}
+		breakdown.Lines = append(breakdown.Lines, Line{
+			Description: "Seasonal rate: " + season.Label,
+			Unit:        "day",
+			Quantity:    count,
+			UnitPrice:   season.PricePerDay,
+			Amount:      count * season.PricePerDay,
+		})
+	}
+	breakdown.Lines = append(breakdown.Lines, tiered(regularDays, DailyRate(product), product.PricePerWeek, product.PricePerMonth)...)
+
+	for _, line := range breakdown.Lines {
+		breakdown.UnitTotal += line.Amount
+	}
+	return breakdown
+}
+
+// QuoteFor menghitung harga sewa untuk sejumlah unit
+func QuoteFor(product models.Product, seasons []models.SeasonalPrice, startDate, endDate time.Time, quantity int) (Quote, error) {
+	breakdown, err := Price(product, seasons, startDate, endDate)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{Breakdown: breakdown, Quantity: quantity, Subtotal: breakdown.UnitTotal * quantity}, nil
+}
+
+// LateFeePerDay menghitung denda keterlambatan per hari sebuah order, yaitu
+// tarif harian saat order dibuat dikali jumlah unit setiap item
+func LateFeePerDay(items []models.OrderItem) int {
+	fee := 0
+	for _, item := range items {
+		fee += item.PriceAtTimeOfOrder * item.Quantity
+	}
+	return fee
+}
+
+// LateDays menghitung jumlah hari penuh yang sudah lewat sejak tanggal akhir sewa
+func LateDays(endDate, now time.Time) int {
+	end := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 0, 0, 0, 0, time.UTC)
+	if !now.After(end) {
+		return 0
+	}
+	return int(now.Sub(end) / (24 * time.Hour))
+}
+
+func tiered(days, daily, weekly, monthly int) []Line {
+	months, weeks := 0, 0
+	if monthly > 0 {
+		months, days = days/daysPerMonth, days%daysPerMonth
+	}
+	if weekly > 0 {
+		weeks, days = days/daysPerWeek, days%daysPerWeek
+		if days > 0 && days*daily >= weekly {
+			weeks, days = weeks+1, 0
+		}
+	}
+	if monthly > 0 && (weeks > 0 || days > 0) && weeks*weekly+days*daily >= monthly {
+		months, weeks, days = months+1, 0, 0
+	}
+
+	var lines []Line
+	if months > 0 {
+		lines = append(lines, Line{Description: "Monthly rate", Unit: "month", Quantity: months, UnitPrice: monthly, Amount: months * monthly})
+	}
+	if weeks > 0 {
+		lines = append(lines, Line{Description: "Weekly rate", Unit: "week", Quantity: weeks, UnitPrice: weekly, Amount: weeks * weekly})
+	}
+	if days > 0 {
+		lines = append(lines, Line{Description: "Daily rate", Unit: "day", Quantity: days, UnitPrice: daily, Amount: days * daily})
+	}
+	return lines
+}
+
+// seasonFor mencari harga musiman untuk satu hari. Tanggal akhir musim ikut dihitung.
+func seasonFor(seasons []models.SeasonalPrice, day time.Time) (models.SeasonalPrice, bool) {
+	for _, season := range seasons {
+		start := time.Date(season.StartDate.Year(), season.StartDate.Month(), season.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+		end := time.Date(season.EndDate.Year(), season.EndDate.Month(), season.EndDate.Day(), 0, 0, 0, 0, time.UTC)
+		if !day.Before(start) && !day.After(end) {
+			return season, true
+		}
+	}
+	return models.SeasonalPrice{}, false
+}
@@ -0,0 +1,171 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func day(n int) time.Time {
+	return time.Date(2030, time.March, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n)
+}
+
+// summarize meringkas baris harga menjadi misalnya "1 month, 2 day"
+func summarize(lines []Line) string {
+	var parts []string
+	for _, line := range lines {
+		parts = append(parts, fmt.Sprintf("%d %s", line.Quantity, line.Unit))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func TestTiered(t *testing.T) {
+	tests := []struct {
+		name                   string
+		days                   int
+		daily, weekly, monthly int
+		want                   string
+		wantTotal              int
+	}{
+		{"daily rate only", 3, 10000, 0, 0, "3 day", 30000},
+		{"leftover below a week stays daily", 4, 10000, 50000, 150000, "4 day", 40000},
+		{"leftover rolls up to a week", 5, 10000, 50000, 150000, "1 week", 50000},
+		{"week plus a day", 8, 10000, 50000, 150000, "1 week, 1 day", 60000},
+		{"leftover rolls up to a second week", 12, 10000, 50000, 150000, "2 week", 100000},
+		{"exact month", 30, 10000, 50000, 150000, "1 month", 150000},
+		{"weeks roll up to a month", 25, 10000, 50000, 150000, "1 month", 150000},
+		{"month plus leftover days", 33, 10000, 50000, 150000, "1 month, 3 day", 180000},
+		{"month with only a weekly rate", 30, 10000, 50000, 0, "4 week, 2 day", 220000},
+		{"monthly rate without weekly rate", 40, 10000, 0, 150000, "1 month, 10 day", 250000},
+		{"leftover rolls up to a month without weekly rate", 45, 10000, 0, 150000, "2 month", 300000},
+		{"no days", 0, 10000, 50000, 150000, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := tiered(tt.days, tt.daily, tt.weekly, tt.monthly)
+			total := 0
+			for _, line := range lines {
+				total += line.Amount
+			}
+			if got := summarize(lines); got != tt.want || total != tt.wantTotal {
+				t.Errorf("tiered(%d) = %q (%d), want %q (%d)", tt.days, got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestPriceSeasons(t *testing.T) {
+	product := models.Product{Name: "Scaffolding", PricePerDay: 10000, PricePerWeek: 50000}
+	season := func(start, end int) []models.SeasonalPrice {
+		return []models.SeasonalPrice{{ID: uuid.New(), Label: "Peak", StartDate: day(start), EndDate: day(end), PricePerDay: 20000}}
+	}
+
+	tests := []struct {
+		name      string
+		seasons   []models.SeasonalPrice
+		start     int
+		end       int
+		wantDays  int
+		want      string
+		wantTotal int
+	}{
+		{"no season", nil, 0, 3, 3, "3 day", 30000},
+		{"season end date is inclusive", season(2, 4), 0, 5, 5, "3 day, 2 day", 80000},
+		{"rental end date is exclusive", season(2, 4), 0, 4, 4, "2 day, 2 day", 60000},
+		{"season overlaps the start", season(-3, 1), 0, 5, 5, "2 day, 3 day", 70000},
+		{"season overlaps the end", season(4, 10), 0, 5, 5, "1 day, 4 day", 60000},
+		{"season outside the rental", season(6, 9), 0, 4, 4, "4 day", 40000},
+		{"seasonal days do not count toward the weekly rate", season(2, 4), 0, 10, 10, "3 day, 1 week", 110000},
+		{"same-day rental counts one day", nil, 0, 0, 1, "1 day", 10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Price(product, tt.seasons, day(tt.start), day(tt.end))
+			if err != nil {
+				t.Fatalf("Price: %v", err)
+			}
+			if got.Days != tt.wantDays || summarize(got.Lines) != tt.want || got.UnitTotal != tt.wantTotal {
+				t.Errorf("Price = %d days, %q (%d), want %d days, %q (%d)",
+					got.Days, summarize(got.Lines), got.UnitTotal, tt.wantDays, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestPriceRentalDays(t *testing.T) {
+	product := models.Product{Name: "Scaffolding", PricePerDay: 10000, MinRentalDays: 3, MaxRentalDays: 10}
+	tests := []struct {
+		name    string
+		days    int
+		wantErr bool
+	}{
+		{"below minimum", 2, true},
+		{"at minimum", 3, false},
+		{"at maximum", 10, false},
+		{"above maximum", 11, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Price(product, nil, day(0), day(tt.days))
+			var rentalErr *RentalError
+			if got := errors.As(err, &rentalErr); got != tt.wantErr {
+				t.Errorf("Price(%d days) error = %v, want rental error %v", tt.days, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestExtensionPriceConsecutive memperpanjang order dua kali berturut-turut;
+// perpanjangan kedua tidak boleh menagih ulang hari dari perpanjangan pertama
+func TestExtensionPriceConsecutive(t *testing.T) {
+	product := models.Product{Name: "Scaffolding", PricePerDay: 10000, PricePerWeek: 50000}
+	tests := []struct {
+		name      string
+		ends      []int
+		wantSteps []int
+	}{
+		{"daily days", []int{3, 4, 5}, []int{10000, 10000}},
+		{"second extension reaches the weekly rate", []int{3, 4, 8}, []int{10000, 20000}},
+		{"first extension reaches the weekly rate", []int{3, 5, 8}, []int{20000, 10000}},
+		{"extension inside a paid week is free", []int{5, 6, 7}, []int{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total := 0
+			for i, want := range tt.wantSteps {
+				got, err := ExtensionPrice(product, nil, day(0), day(tt.ends[i]), day(tt.ends[i+1]))
+				if err != nil {
+					t.Fatalf("extension %d: %v", i+1, err)
+				}
+				if got != want {
+					t.Errorf("extension %d (day %d to %d) = %d, want %d", i+1, tt.ends[i], tt.ends[i+1], got, want)
+				}
+				total += got
+			}
+			first, _ := Price(product, nil, day(0), day(tt.ends[0]))
+			last, _ := Price(product, nil, day(0), day(tt.ends[len(tt.ends)-1]))
+			if total != last.UnitTotal-first.UnitTotal {
+				t.Errorf("extensions total %d, want %d", total, last.UnitTotal-first.UnitTotal)
+			}
+		})
+	}
+}
+
+// Produk yang minimal sewanya dinaikkan setelah order dibuat tetap bisa diperpanjang
+// selama periode barunya memenuhi aturan
+func TestExtensionPriceChecksNewPeriodOnly(t *testing.T) {
+	product := models.Product{Name: "Scaffolding", PricePerDay: 10000, MinRentalDays: 5, MaxRentalDays: 7}
+	if got, err := ExtensionPrice(product, nil, day(0), day(3), day(5)); err != nil || got != 20000 {
+		t.Errorf("ExtensionPrice(3 to 5 days) = %d, %v; want 20000, nil", got, err)
+	}
+	var rentalErr *RentalError
+	if _, err := ExtensionPrice(product, nil, day(0), day(5), day(8)); !errors.As(err, &rentalErr) {
+		t.Errorf("ExtensionPrice beyond max rental days error = %v, want rental error", err)
+	}
+}
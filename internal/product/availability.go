@@ -0,0 +1,106 @@
+// Lokasi: internal/product/availability.go
+package product
+
+import (
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/reservation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxCalendarDays membatasi rentang kalender agar cukup untuk tampilan beberapa bulan
+const maxCalendarDays = 93
+
+type AvailabilityResponse struct {
+	ProductID uuid.UUID                     `json:"product_id"`
+	Stock     int                           `json:"stock"`
+	From      string                        `json:"from"`
+	To        string                        `json:"to"`
+	Days      []reservation.DayAvailability `json:"days"`
+}
+
+// GetProductAvailability menampilkan jumlah unit yang masih bisa disewa per hari
+// pada rentang from sampai to (YYYY-MM-DD, to ikut dihitung), memakai perhitungan
+// stok yang sama dengan CreateOrder
+func (h *Handler) GetProductAvailability(c *gin.Context) {
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from format, use YYYY-MM-DD"})
+		return
+	}
+	to, err := time.Parse("2006-01-02", c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to format, use YYYY-MM-DD"})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+	if int(to.Sub(from).Hours()/24)+1 > maxCalendarDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date range is too long, the maximum is 93 days"})
+		return
+	}
+
+	var product models.Product
+	if err := h.DB.First(&product, "id = ?", c.Param("productId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	days, err := reservation.Calendar(h.DB, product, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate availability"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AvailabilityResponse{
+		ProductID: product.ID,
+		Stock:     product.Stock,
+		From:      from.Format("2006-01-02"),
+		To:        to.Format("2006-01-02"),
+		Days:      days,
+	})
+}
+
+// filterAvailable menyisakan produk yang masih punya stok pada rentang tanggal,
+// dihitung dengan puncak pemakaian per hari seperti saat checkout
+func (h *Handler) filterAvailable(list []ProductListResponse, startDate, endDate time.Time) ([]ProductListResponse, error) {
+	if len(list) == 0 {
+		return list, nil
+	}
+	ids := make([]uuid.UUID, 0, len(list))
+	for _, item := range list {
+		ids = append(ids, item.ID)
+	}
+	var products []models.Product
+	if err := h.DB.Select("id", "stock").Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	available, err := reservation.AvailableByProduct(h.DB, products, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]ProductListResponse, 0, len(list))
+	for _, item := range list {
+		if available[item.ID] > 0 {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
+
+func paginate(list []ProductListResponse, offset, limit int) []ProductListResponse {
+	if offset >= len(list) {
+		return nil
+	}
+	end := offset + limit
+	if end > len(list) {
+		end = len(list)
+	}
+	return list[offset:end]
+}
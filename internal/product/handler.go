@@ -3,14 +3,15 @@ package product
 
 import (
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"time"
 
+	"sewascaf.com/api/internal/geo"
+	"sewascaf.com/api/internal/middleware"
 	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -32,22 +33,9 @@ func NewHandler(db *gorm.DB, supabaseURL string, supabaseServiceKey string) *Han
 }
 
 func (h *Handler) CreateProduct(c *gin.Context) {
-	// 1. Dapatkan userID dari token
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
-		return
-	}
-
-	userIDString, ok := userIDInterface.(string)
+	// 1-2. Role pengusaha sudah dicek RequireRole, toko diambil dari claim token
+	shopID, ok := middleware.ShopID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
-		return
-	}
-
-	// 2. Verifikasi role user adalah 'pengusaha' dan dapatkan tokonya
-	var shop models.Shop
-	if err := h.DB.Select("id").Where("user_id = ?", userIDString).First(&shop).Error; err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "User does not own a shop"})
 		return
 	}
@@ -77,21 +65,17 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock format"})
 		return
 	}
+
+	// Tarif mingguan/bulanan, batas durasi sewa, dan deposit bersifat opsional, kosong berarti 0
+	pricePerWeek, _ := strconv.Atoi(c.PostForm("price_per_week"))
+	pricePerMonth, _ := strconv.Atoi(c.PostForm("price_per_month"))
+	minRentalDays, _ := strconv.Atoi(c.PostForm("min_rental_days"))
+	maxRentalDays, _ := strconv.Atoi(c.PostForm("max_rental_days"))
+	depositAmount, _ := strconv.Atoi(c.PostForm("deposit_amount"))
 	
 	// 5. Upload gambar ke Supabase Storage (di bucket 'product-images')
-	fileName := fmt.Sprintf("%s-%s", uuid.New().String(), filepath.Base(file.Filename))
-	uploadURL := fmt.Sprintf("%s/storage/v1/object/product-images/%s", h.SupabaseURL, fileName)
-	
-	src, _ := file.Open()
-	defer src.Close()
-
-	req, _ := http.NewRequest("POST", uploadURL, src)
-	req.Header.Set("Authorization", "Bearer "+h.SupabaseServiceKey)
-	req.Header.Set("Content-Type", file.Header.Get("Content-Type"))
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
+	imageURL, err := storage.NewSupabase(h.SupabaseURL, h.SupabaseServiceKey).Upload("product-images", file)
+	if err != nil {
 		log.Printf("Failed to upload product image: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload product image"})
 		return
@@ -100,14 +84,19 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 	// 6. Buat produk baru di database
 	newProduct := models.Product{
 		ID:                  uuid.New(),
-		ShopID:              shop.ID,
+		ShopID:              shopID,
 		SKU:                 c.PostForm("sku"),
 		Name:                c.PostForm("name"),
 		Description:         c.PostForm("description"),
 		PricePerDay:         price,
 		DiscountPricePerDay: discountPrice,
 		Stock:               stock,
-		ImageURL:            fmt.Sprintf("%s/storage/v1/object/public/product-images/%s", h.SupabaseURL, fileName),
+		PricePerWeek:        pricePerWeek,
+		PricePerMonth:       pricePerMonth,
+		MinRentalDays:       minRentalDays,
+		MaxRentalDays:       maxRentalDays,
+		DepositAmount:       depositAmount,
+		ImageURL:            imageURL,
 	}
 
 	if result := h.DB.Create(&newProduct); result.Error != nil {
@@ -119,20 +108,14 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 }
 
 func (h *Handler) GetShopProducts(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
-		return
-	}
-
-	var shop models.Shop
-	if err := h.DB.Select("id").Where("user_id = ?", userID).First(&shop).Error; err != nil {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
 		c.JSON(http.StatusForbidden, gin.H{"error": "User does not own a shop"})
 		return
 	}
 
 	var products []models.Product
-	if err := h.DB.Where("shop_id = ?", shop.ID).Find(&products).Error; err != nil {
+	if err := h.DB.Where("shop_id = ?", shopID).Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve products"})
 		return
 	}
@@ -150,16 +133,21 @@ type UpdateProductPayload struct {
 	PricePerDay         int    `json:"price_per_day"`
 	DiscountPricePerDay int    `json:"discount_price_per_day"`
 	Stock               int    `json:"stock"`
+	PricePerWeek        int    `json:"price_per_week"`
+	PricePerMonth       int    `json:"price_per_month"`
+	MinRentalDays       int    `json:"min_rental_days"`
+	MaxRentalDays       int    `json:"max_rental_days"`
+	DepositAmount       int    `json:"deposit_amount"`
 }
 
 func (h *Handler) UpdateProduct(c *gin.Context) {
 	// 1. Dapatkan productID dari URL
 	productID := c.Param("productId")
 
-	// 2. Dapatkan userID dari token
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+	// 2. Dapatkan toko dari claim token
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User does not own a shop"})
 		return
 	}
 
@@ -172,19 +160,13 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 
 	// 4. Lakukan Transaction untuk keamanan
 	err := h.DB.Transaction(func(tx *gorm.DB) error {
-		// Langkah A: Cari toko milik user untuk mendapatkan ShopID
-		var shop models.Shop
-		if err := tx.Select("id").Where("user_id = ?", userID).First(&shop).Error; err != nil {
-			return errors.New("shop not found for this user")
-		}
-
-		// Langkah B: Cari produk berdasarkan ID-nya DAN pastikan produk itu milik toko si user
+		// Langkah A: Cari produk berdasarkan ID-nya DAN pastikan produk itu milik toko si user
 		var product models.Product
-		if err := tx.Where("id = ? AND shop_id = ?", productID, shop.ID).First(&product).Error; err != nil {
+		if err := tx.Where("id = ? AND shop_id = ?", productID, shopID).First(&product).Error; err != nil {
 			return errors.New("product not found or you do not have permission to edit it")
 		}
 
-		// Langkah C: Lakukan update
+		// Langkah B: Lakukan update
 		if err := tx.Model(&product).Updates(payload).Error; err != nil {
 			return err
 		}
@@ -207,20 +189,15 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 func (h *Handler) DeleteProduct(c *gin.Context) {
 	productID := c.Param("productId")
 
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User does not own a shop"})
 		return
 	}
 
 	err := h.DB.Transaction(func(tx *gorm.DB) error {
-		var shop models.Shop
-		if err := tx.Select("id").Where("user_id = ?", userID).First(&shop).Error; err != nil {
-			return errors.New("shop not found for this user")
-		}
-
 		var product models.Product
-		if err := tx.Where("id = ? AND shop_id = ?", productID, shop.ID).First(&product).Error; err != nil {
+		if err := tx.Where("id = ? AND shop_id = ?", productID, shopID).First(&product).Error; err != nil {
 			return errors.New("product not found or you do not have permission to delete it")
 		}
 
@@ -310,6 +287,7 @@ type ProductListResponse struct {
 	ImageURL            string    `json:"image_url"`
 	ShopName            string    `json:"shop_name"`
 	AverageRating       float64   `json:"average_rating"`
+	DistanceKm          *float64  `json:"distance_km,omitempty"`
 }
 
 func (h *Handler) GetProducts(c *gin.Context) {
@@ -327,6 +305,25 @@ func (h *Handler) GetProducts(c *gin.Context) {
 	startDateStr := c.Query("start_date")
 	endDateStr := c.Query("end_date")
 
+	// Pencarian berdasarkan jarak dari lokasi proyek penyewa (lat, lng, radius_km)
+	origin, err := parseOrigin(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	radiusKm := 0.0
+	if radiusStr := c.Query("radius_km"); radiusStr != "" {
+		radiusKm, err = strconv.ParseFloat(radiusStr, 64)
+		if err != nil || radiusKm <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radius_km must be a positive number"})
+			return
+		}
+	}
+	if origin == nil && (radiusKm > 0 || sortOption == "distance") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng are required for radius_km or sort=distance"})
+		return
+	}
+
 	var response []ProductListResponse
 	
 	// REVISI TOTAL: Query sekarang menggabungkan 3 tabel dan menghitung rata-rata rating
@@ -344,6 +341,25 @@ func (h *Handler) GetProducts(c *gin.Context) {
 		Joins("LEFT JOIN reviews ON reviews.product_id = products.id"). // LEFT JOIN agar produk tanpa review tetap muncul
 		Group("products.id, shops.shop_name") // Group by untuk fungsi agregat AVG()
 
+	if origin != nil {
+		distance := geo.DistanceSQL("shops.latitude", "shops.longitude")
+		query = query.
+			Select(`
+				products.id,
+				products.name,
+				products.price_per_day,
+				products.discount_price_per_day,
+				products.image_url,
+				shops.shop_name,
+				COALESCE(AVG(reviews.rating), 0) as average_rating,
+				`+distance+` as distance_km
+			`, origin.Lat, origin.Lat, origin.Lng).
+			Group("shops.latitude, shops.longitude")
+		if radiusKm > 0 {
+			query = query.Where(distance+" <= ?", origin.Lat, origin.Lat, origin.Lng, radiusKm)
+		}
+	}
+
 	if searchQuery != "" {
 		query = query.Where("products.name ILIKE ?", "%"+searchQuery+"%")
 	}
@@ -359,17 +375,23 @@ func (h *Handler) GetProducts(c *gin.Context) {
 		}
 	}
 
+	var startDate, endDate time.Time
+	dateFilter := false
 	if startDateStr != "" && endDateStr != "" {
-		startDate, err1 := time.Parse("2006-01-02", startDateStr)
-		endDate, err2 := time.Parse("2006-01-02", endDateStr)
+		var err1, err2 error
+		startDate, err1 = time.Parse("2006-01-02", startDateStr)
+		endDate, err2 = time.Parse("2006-01-02", endDateStr)
 
 		if err1 == nil && err2 == nil {
-			subQuery := h.DB.Model(&models.OrderItem{}).
-				Select("COALESCE(SUM(order_items.quantity), 0)").
-				Joins("JOIN orders ON orders.id = order_items.order_id").
-				Where("order_items.product_id = products.id AND orders.status IN ('active', 'pending') AND (orders.start_date, orders.end_date) OVERLAPS (?, ?)", startDate, endDate)
-
-			query = query.Where("products.stock > (?)", subQuery)
+			dateFilter = true
+			// Sisa stok per tanggal dihitung setelah query, sama seperti saat checkout
+			query = query.Where("products.stock > 0")
+
+			// Toko yang libur di tanggal ambil atau kembali tidak ditampilkan
+			closedQuery := h.DB.Model(&models.ShopClosure{}).
+				Select("1").
+				Where("shop_closures.shop_id = shops.id AND ((shop_closures.start_date <= ? AND shop_closures.end_date >= ?) OR (shop_closures.start_date <= ? AND shop_closures.end_date >= ?))", startDate, startDate, endDate, endDate)
+			query = query.Where("NOT EXISTS (?)", closedQuery)
 		}
 	}
 
@@ -380,9 +402,15 @@ func (h *Handler) GetProducts(c *gin.Context) {
 		query = query.Order("products.price_per_day DESC")
 	case "rating_desc":
 		query = query.Order("average_rating DESC")
+	case "distance":
+		// Toko yang belum punya koordinat ditaruh paling akhir
+		query = query.Order("distance_km ASC NULLS LAST")
 	}
 
-	if err := query.Offset(offset).Limit(limit).Scan(&response).Error; err != nil {
+	if !dateFilter {
+		query = query.Offset(offset).Limit(limit)
+	}
+	if err := query.Scan(&response).Error; err != nil {
 		log.Printf("!!! GORM GET PRODUCTS FAILED !!! Error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to retrieve products",
@@ -391,6 +419,16 @@ func (h *Handler) GetProducts(c *gin.Context) {
 		return
 	}
 	
+	if dateFilter {
+		// Produk yang penuh pada tanggal tersebut disaring dulu, baru halaman dipotong
+		available, err := h.filterAvailable(response, startDate, endDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check product availability"})
+			return
+		}
+		response = paginate(available, offset, limit)
+	}
+
 	if response == nil {
 		response = make([]ProductListResponse, 0)
 	}
@@ -398,6 +436,25 @@ func (h *Handler) GetProducts(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// parseOrigin membaca titik asal pencarian dari query lat dan lng.
+// Mengembalikan nil jika keduanya kosong.
+func parseOrigin(c *gin.Context) (*geo.Point, error) {
+	latStr, lngStr := c.Query("lat"), c.Query("lng")
+	if latStr == "" && lngStr == "" {
+		return nil, nil
+	}
+	lat, err1 := strconv.ParseFloat(latStr, 64)
+	lng, err2 := strconv.ParseFloat(lngStr, 64)
+	if err1 != nil || err2 != nil {
+		return nil, errors.New("lat and lng must both be valid numbers")
+	}
+	origin := geo.Point{Lat: lat, Lng: lng}
+	if !origin.Valid() {
+		return nil, errors.New("lat or lng is out of range")
+	}
+	return &origin, nil
+}
+
 type ProductDetailResponse struct {
 	models.Product        
 	Shop           models.Shop      `json:"shop"`
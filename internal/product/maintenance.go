@@ -0,0 +1,144 @@
+// Lokasi: internal/product/maintenance.go
+package product
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/middleware"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/reservation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var errMaintenanceConflict = errors.New("not enough free units on those dates, the units are already booked")
+
+type MaintenanceBlockPayload struct {
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date" binding:"required"`
+	Reason    string `json:"reason"`
+}
+
+// GetMaintenanceBlocks menampilkan jadwal perawatan sebuah produk
+func (h *Handler) GetMaintenanceBlocks(c *gin.Context) {
+	var blocks []models.MaintenanceBlock
+	if err := h.DB.Where("product_id = ?", c.Param("productId")).Order("start_date ASC").Find(&blocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve maintenance blocks"})
+		return
+	}
+	if blocks == nil {
+		blocks = make([]models.MaintenanceBlock, 0)
+	}
+	c.JSON(http.StatusOK, blocks)
+}
+
+// CreateMaintenanceBlock menonaktifkan sejumlah unit produk milik toko user pada
+// rentang tanggal tertentu (tanggal akhir ikut dihitung). Unit yang sudah disewa
+// pada tanggal tersebut tidak bisa ditarik.
+func (h *Handler) CreateMaintenanceBlock(c *gin.Context) {
+	productID := c.Param("productId")
+
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": errShopNotFound.Error()})
+		return
+	}
+
+	var payload MaintenanceBlockPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	startDate, err := time.Parse("2006-01-02", payload.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format, use YYYY-MM-DD"})
+		return
+	}
+	endDate, err := time.Parse("2006-01-02", payload.EndDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format, use YYYY-MM-DD"})
+		return
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return
+	}
+
+	var block models.MaintenanceBlock
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		owned, err := findOwnedProduct(tx, shopID, productID)
+		if err != nil {
+			return err
+		}
+		// Kunci produk agar tidak ada order baru yang memakai unit yang sama
+		products, err := reservation.LockProducts(tx, []uuid.UUID{owned.ID})
+		if err != nil {
+			return err
+		}
+		available, err := reservation.Available(tx, products[owned.ID], startDate, endDate.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		if available < payload.Quantity {
+			return errMaintenanceConflict
+		}
+
+		block = models.MaintenanceBlock{
+			ID:        uuid.New(),
+			ProductID: owned.ID,
+			Quantity:  payload.Quantity,
+			StartDate: startDate,
+			EndDate:   endDate,
+			Reason:    payload.Reason,
+		}
+		return tx.Create(&block).Error
+	})
+
+	if err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, errMaintenanceConflict) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, block)
+}
+
+// DeleteMaintenanceBlock mengembalikan unit yang dirawat sehingga bisa disewa lagi
+func (h *Handler) DeleteMaintenanceBlock(c *gin.Context) {
+	productID := c.Param("productId")
+	blockID := c.Param("blockId")
+
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": errShopNotFound.Error()})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		product, err := findOwnedProduct(tx, shopID, productID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND product_id = ?", blockID, product.ID).Delete(&models.MaintenanceBlock{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("maintenance block not found")
+		}
+		return nil
+	})
+
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
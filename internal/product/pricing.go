@@ -0,0 +1,203 @@
+// Lokasi: internal/product/pricing.go
+package product
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sewascaf.com/api/internal/middleware"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/pricing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	errSeasonOverlap   = errors.New("seasonal price overlaps an existing seasonal price")
+	errShopNotFound    = errors.New("shop not found for this user")
+	errProductNotOwned = errors.New("product not found or you do not have permission to edit it")
+)
+
+// GetProductQuote menghitung rincian harga sewa satu produk untuk periode dan jumlah tertentu
+func (h *Handler) GetProductQuote(c *gin.Context) {
+	productID := c.Param("productId")
+
+	startDate, err := time.Parse("2006-01-02", c.Query("start_date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format, use YYYY-MM-DD"})
+		return
+	}
+	endDate, err := time.Parse("2006-01-02", c.Query("end_date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format, use YYYY-MM-DD"})
+		return
+	}
+	quantity, err := strconv.Atoi(c.DefaultQuery("quantity", "1"))
+	if err != nil || quantity < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quantity"})
+		return
+	}
+
+	var product models.Product
+	if err := h.DB.First(&product, "id = ?", productID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	seasons, err := pricing.LoadSeasons(h.DB, []uuid.UUID{product.ID}, startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seasonal prices"})
+		return
+	}
+
+	quote, err := pricing.QuoteFor(product, seasons[product.ID], startDate, endDate, quantity)
+	if err != nil {
+		var rentalErr *pricing.RentalError
+		if errors.As(err, &rentalErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
+// GetSeasonalPrices menampilkan daftar harga musiman sebuah produk
+func (h *Handler) GetSeasonalPrices(c *gin.Context) {
+	var seasons []models.SeasonalPrice
+	if err := h.DB.Where("product_id = ?", c.Param("productId")).Order("start_date ASC").Find(&seasons).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve seasonal prices"})
+		return
+	}
+	if seasons == nil {
+		seasons = make([]models.SeasonalPrice, 0)
+	}
+	c.JSON(http.StatusOK, seasons)
+}
+
+type SeasonalPricePayload struct {
+	Label       string `json:"label" binding:"required"`
+	StartDate   string `json:"start_date" binding:"required"`
+	EndDate     string `json:"end_date" binding:"required"`
+	PricePerDay int    `json:"price_per_day" binding:"required,gt=0"`
+}
+
+// CreateSeasonalPrice menambahkan harga musiman untuk produk milik toko user
+func (h *Handler) CreateSeasonalPrice(c *gin.Context) {
+	productID := c.Param("productId")
+
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": errShopNotFound.Error()})
+		return
+	}
+
+	var payload SeasonalPricePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	startDate, err := time.Parse("2006-01-02", payload.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format, use YYYY-MM-DD"})
+		return
+	}
+	endDate, err := time.Parse("2006-01-02", payload.EndDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format, use YYYY-MM-DD"})
+		return
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return
+	}
+
+	var season models.SeasonalPrice
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		product, err := findOwnedProduct(tx, shopID, productID)
+		if err != nil {
+			return err
+		}
+
+		var overlapping int64
+		err = tx.Model(&models.SeasonalPrice{}).Where("product_id = ? AND start_date <= ? AND end_date >= ?", product.ID, endDate, startDate).Count(&overlapping).Error
+		if err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return errSeasonOverlap
+		}
+
+		season = models.SeasonalPrice{
+			ID:          uuid.New(),
+			ProductID:   product.ID,
+			Label:       payload.Label,
+			StartDate:   startDate,
+			EndDate:     endDate,
+			PricePerDay: payload.PricePerDay,
+		}
+		return tx.Create(&season).Error
+	})
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errSeasonOverlap):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errShopNotFound), errors.Is(err, errProductNotOwned):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create seasonal price"})
+		return
+	}
+	c.JSON(http.StatusCreated, season)
+}
+
+// DeleteSeasonalPrice menghapus harga musiman produk milik toko user
+func (h *Handler) DeleteSeasonalPrice(c *gin.Context) {
+	productID := c.Param("productId")
+	seasonID := c.Param("seasonId")
+
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": errShopNotFound.Error()})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		product, err := findOwnedProduct(tx, shopID, productID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND product_id = ?", seasonID, product.ID).Delete(&models.SeasonalPrice{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("seasonal price not found")
+		}
+		return nil
+	})
+
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// findOwnedProduct mencari produk dan memastikan produk itu milik toko shopID
+func findOwnedProduct(tx *gorm.DB, shopID uuid.UUID, productID string) (models.Product, error) {
+	var product models.Product
+	if err := tx.Where("id = ? AND shop_id = ?", productID, shopID).First(&product).Error; err != nil {
+		return models.Product{}, errProductNotOwned
+	}
+	return product, nil
+}
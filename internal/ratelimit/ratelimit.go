@@ -0,0 +1,69 @@
+// Lokasi: internal/ratelimit/ratelimit.go
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Store menyimpan hitungan request per key dalam window waktu tetap. Implementasi
+// in-memory cukup untuk satu instance; store bersama (misalnya Redis) dibutuhkan
+// jika API dijalankan di beberapa instance.
+type Store interface {
+	// Hit menambah hitungan key di window berjalan lalu mengembalikan
+	// jumlah request di window tersebut dan waktu window di-reset
+	Hit(ctx context.Context, key string, window time.Duration) (count int, reset time.Time, err error)
+}
+
+// NewStore membuat store sesuai konfigurasi RATE_LIMIT_STORE. Saat ini hanya "memory".
+func NewStore(kind string) (Store, error) {
+	switch kind {
+	case "memory", "":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", kind)
+	}
+}
+
+type counter struct {
+	count int
+	reset time.Time
+}
+
+// MemoryStore adalah Store in-memory dengan fixed window. Key yang window-nya
+// sudah lewat dibersihkan secara berkala agar memori tidak terus bertambah.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*counter), now: time.Now}
+}
+
+func (s *MemoryStore) Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.After(s.nextSweep) {
+		for k, c := range s.counters {
+			if !now.Before(c.reset) {
+				delete(s.counters, k)
+			}
+		}
+		s.nextSweep = now.Add(time.Minute)
+	}
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.reset) {
+		c = &counter{reset: now.Add(window)}
+		s.counters[key] = c
+	}
+	c.count++
+	return c.count, c.reset, nil
+}
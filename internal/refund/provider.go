@@ -0,0 +1,86 @@
+// Lokasi: internal/refund/provider.go
+package refund
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Request adalah permintaan pengembalian dana ke penyewa untuk satu pembayaran Tripay
+type Request struct {
+	RefundID         uuid.UUID
+	OrderID          uuid.UUID
+	PaymentReference string
+	Amount           int
+	Reason           string
+}
+
+// Result adalah hasil dari provider. Status StatusSucceeded berarti dana sudah
+// dikembalikan, StatusPending berarti masih menunggu konfirmasi dari luar.
+type Result struct {
+	ProviderRef string
+	Status      string
+}
+
+// Provider memproses pengembalian dana. Implementasinya bisa diganti tanpa
+// mengubah alur pembatalan order.
+type Provider interface {
+	Name() string
+	Refund(ctx context.Context, req Request) (*Result, error)
+}
+
+// ManualProvider mencatat refund untuk diproses manual lewat dashboard Tripay.
+// Refund selesai saat Tripay mengirim callback berstatus REFUND.
+type ManualProvider struct{}
+
+func (ManualProvider) Name() string { return "manual" }
+
+func (ManualProvider) Refund(ctx context.Context, req Request) (*Result, error) {
+	return &Result{ProviderRef: req.PaymentReference, Status: StatusPending}, nil
+}
+
+// FakeProvider langsung menganggap refund berhasil dan menyimpan semua
+// permintaan di memori. Dipakai untuk development lokal dan pengujian.
+type FakeProvider struct {
+	mu       sync.Mutex
+	requests []Request
+	// Err, jika diisi, dikembalikan oleh setiap pemanggilan Refund
+	Err error
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (f *FakeProvider) Name() string { return "fake" }
+
+func (f *FakeProvider) Refund(ctx context.Context, req Request) (*Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.requests = append(f.requests, req)
+	return &Result{ProviderRef: fmt.Sprintf("FAKE-REFUND-%d", len(f.requests)), Status: StatusSucceeded}, nil
+}
+
+// Requests mengembalikan salinan semua refund yang sudah diproses
+func (f *FakeProvider) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
+
+// NewProvider membuat provider sesuai REFUND_PROVIDER
+func NewProvider(name string) (Provider, error) {
+	switch name {
+	case "", "manual":
+		return ManualProvider{}, nil
+	case "fake":
+		return NewFakeProvider(), nil
+	}
+	return nil, fmt.Errorf("unknown refund provider %q", name)
+}
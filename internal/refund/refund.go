@@ -0,0 +1,209 @@
+// Lokasi: internal/refund/refund.go
+package refund
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"sewascaf.com/api/internal/deposit"
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Status refund
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Aturan kebijakan pembatalan yang berlaku untuk sebuah refund
+const (
+	RuleFull    = "full"
+	RulePartial = "partial"
+	RuleNone    = "none"
+)
+
+var (
+	ErrNotPaid        = errors.New("only paid orders can be cancelled with a refund")
+	ErrAfterPickup    = errors.New("order has been picked up and can no longer be cancelled or refunded")
+	ErrNoPaidPayment  = errors.New("no paid Tripay payment found for this order")
+	ErrAlreadyRefunds = errors.New("order already has a refund")
+)
+
+// Decision adalah hasil perhitungan kebijakan pembatalan untuk sebuah order.
+// Deposit selalu dikembalikan penuh karena belum ada barang yang disewa.
+type Decision struct {
+	Rule            string `json:"rule"`
+	Percent         int    `json:"percent"`
+	DaysBeforeStart int    `json:"days_before_start"`
+	RentalRefund    int    `json:"rental_refund"`
+	DepositRefund   int    `json:"deposit_refund"`
+	Amount          int    `json:"amount"`
+}
+
+// Evaluate menghitung dana yang bisa dikembalikan jika penyewa membatalkan order
+// sekarang: penuh sampai CancellationFullRefundDays hari sebelum tanggal mulai,
+// sebagian setelahnya, dan tidak bisa dibatalkan setelah barang diambil.
+func Evaluate(order models.Order, shop models.Shop, now time.Time) (Decision, error) {
+	switch order.Status {
+	case lifecycle.StatusPaid:
+	case lifecycle.StatusAwaitingApproval:
+		// Toko belum menyetujui order, jadi penyewa selalu mendapat refund penuh
+		return Full(order), nil
+	case lifecycle.StatusPickedUp, lifecycle.StatusReturned, lifecycle.StatusCompleted:
+		return Decision{Rule: RuleNone}, ErrAfterPickup
+	default:
+		return Decision{}, ErrNotPaid
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start := time.Date(order.StartDate.Year(), order.StartDate.Month(), order.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+	daysBefore := int(start.Sub(today).Hours() / 24)
+
+	decision := Decision{Rule: RuleFull, Percent: 100, DaysBeforeStart: daysBefore}
+	if daysBefore < shop.CancellationFullRefundDays {
+		decision.Rule = RulePartial
+		decision.Percent = shop.CancellationPartialRefundPercent
+	}
+	// Ongkos kirim selalu dikembalikan penuh karena barang belum diantar
+	decision.RentalRefund = (order.TotalPrice-order.DeliveryFee)*decision.Percent/100 + order.DeliveryFee
+	decision.DepositRefund = order.DepositAmount
+	decision.Amount = decision.RentalRefund + decision.DepositRefund
+	return decision, nil
+}
+
+// Full adalah refund penuh, dipakai saat toko membatalkan atau menolak order,
+// atau saat order tidak disetujui sebelum batas waktunya
+func Full(order models.Order) Decision {
+	return Decision{
+		Rule:          RuleFull,
+		Percent:       100,
+		RentalRefund:  order.TotalPrice,
+		DepositRefund: order.DepositAmount,
+		Amount:        order.ChargeAmount(),
+	}
+}
+
+// Create mencatat refund pending untuk order yang baru dibatalkan. Dipanggil di
+// transaksi yang sama dengan pembatalan; dana baru diproses oleh Execute setelah commit.
+// Mengembalikan nil jika tidak ada dana yang perlu dikembalikan. Order yang dibayar
+// tanpa baris Payment mendapat refund manual, bukan provider yang diminta.
+func Create(tx *gorm.DB, order models.Order, decision Decision, reason, provider string) (*models.Refund, error) {
+	if decision.Amount <= 0 {
+		return nil, nil
+	}
+
+	var existing int64
+	if err := tx.Model(&models.Refund{}).Where("order_id = ? AND status <> ?", order.ID, StatusFailed).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrAlreadyRefunds
+	}
+
+	// Refund dikirim ke pembayaran utama order (purpose "order"), bukan tagihan tambahan
+	var payments []models.Payment
+	if err := tx.Where("order_id = ? AND purpose = ?", order.ID, "order").Order("created_at DESC").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	var payment *models.Payment
+	for i := range payments {
+		if payments[i].Status == "PAID" {
+			payment = &payments[i]
+			break
+		}
+	}
+	switch {
+	case payment == nil && len(payments) > 0:
+		return nil, ErrNoPaidPayment
+	case payment == nil:
+		// Order lama (status active yang dimigrasi ke paid) tidak punya baris Payment,
+		// jadi dananya dikembalikan manual oleh admin
+		payment = &models.Payment{}
+		provider = ManualProvider{}.Name()
+	}
+
+	refund := models.Refund{
+		ID:               uuid.New(),
+		OrderID:          order.ID,
+		PaymentReference: payment.Reference,
+		Rule:             decision.Rule,
+		Percent:          decision.Percent,
+		RentalRefund:     decision.RentalRefund,
+		DepositRefund:    decision.DepositRefund,
+		Amount:           decision.Amount,
+		Reason:           reason,
+		Provider:         provider,
+		Status:           StatusPending,
+	}
+	if err := tx.Create(&refund).Error; err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// Execute mengirim refund ke provider. Jika provider langsung berhasil, order
+// dipindahkan ke refunded dan sisa depositnya dilepas. Refund yang gagal tetap
+// tercatat dengan status failed untuk ditindaklanjuti manual. Refund yang dicatat
+// untuk provider manual selalu diproses manual apa pun provider yang dikirim.
+func Execute(ctx context.Context, db *gorm.DB, provider Provider, refund *models.Refund) error {
+	if manual := (ManualProvider{}); refund.Provider == manual.Name() {
+		provider = manual
+	}
+	result, err := provider.Refund(ctx, Request{
+		RefundID:         refund.ID,
+		OrderID:          refund.OrderID,
+		PaymentReference: refund.PaymentReference,
+		Amount:           refund.Amount,
+		Reason:           refund.Reason,
+	})
+	if err != nil {
+		refund.Status = StatusFailed
+		refund.FailureReason = err.Error()
+		if updateErr := db.Model(refund).Updates(map[string]interface{}{"status": StatusFailed, "failure_reason": err.Error()}).Error; updateErr != nil {
+			log.Printf("Failed to record failed refund %s: %v", refund.ID, updateErr)
+		}
+		return err
+	}
+
+	refund.ProviderRef = result.ProviderRef
+	if result.Status != StatusSucceeded {
+		return db.Model(refund).Update("provider_ref", result.ProviderRef).Error
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return Complete(tx, refund, result.ProviderRef)
+	})
+}
+
+// Complete menandai refund berhasil, memindahkan order ke refunded, dan
+// melepas sisa deposit. Dipakai oleh Execute dan callback REFUND dari Tripay.
+func Complete(tx *gorm.DB, refund *models.Refund, providerRef string) error {
+	now := time.Now()
+	refund.Status = StatusSucceeded
+	refund.ProcessedAt = &now
+	updates := map[string]interface{}{"status": StatusSucceeded, "processed_at": now}
+	if providerRef != "" {
+		refund.ProviderRef = providerRef
+		updates["provider_ref"] = providerRef
+	}
+	if err := tx.Model(refund).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	var order models.Order
+	if err := tx.First(&order, "id = ?", refund.OrderID).Error; err != nil {
+		return err
+	}
+	if order.Status != lifecycle.StatusRefunded {
+		if err := lifecycle.Transition(tx, &order, lifecycle.StatusRefunded, lifecycle.ActorSystem, nil, "refund completed"); err != nil {
+			return err
+		}
+	}
+	return deposit.Release(tx, order.ID, "deposit refunded with the order", nil)
+}
@@ -0,0 +1,121 @@
+package refund
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/testdb"
+)
+
+// TestCreateWithoutPaymentRow memakai order lama yang dimigrasi dari active ke paid
+// tanpa baris Payment: refund tetap dicatat dan diproses manual
+func TestCreateWithoutPaymentRow(t *testing.T) {
+	db := testdb.Open(t)
+	order := testdb.Order(t, db, testdb.Shop(t, db), testdb.User(t, db, models.RoleUser), lifecycle.StatusPaid)
+
+	created, err := Create(db, order, Full(order), "cancelled by shop", "fake")
+	testdb.Fatal(t, err, "Create")
+	if created == nil || created.Provider != (ManualProvider{}).Name() || created.PaymentReference != "" {
+		t.Fatalf("refund = %+v, want a manual refund without payment reference", created)
+	}
+
+	provider := NewFakeProvider()
+	testdb.Fatal(t, Execute(context.Background(), db, provider, created), "Execute")
+	if len(provider.Requests()) != 0 {
+		t.Errorf("manual refund was sent to the fake provider")
+	}
+	var got models.Refund
+	testdb.Fatal(t, db.First(&got, "id = ?", created.ID).Error, "reload refund")
+	if got.Status != StatusPending {
+		t.Errorf("refund status = %s, want %s", got.Status, StatusPending)
+	}
+}
+
+func TestCreateWithUnpaidPaymentRow(t *testing.T) {
+	db := testdb.Open(t)
+	order := testdb.Order(t, db, testdb.Shop(t, db), testdb.User(t, db, models.RoleUser), lifecycle.StatusPaid)
+	testdb.Payment(t, db, order, "UNPAID")
+
+	if _, err := Create(db, order, Full(order), "cancelled by shop", "fake"); !errors.Is(err, ErrNoPaidPayment) {
+		t.Errorf("Create error = %v, want %v", err, ErrNoPaidPayment)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2030, time.March, 1, 15, 30, 0, 0, time.UTC)
+	shop := models.Shop{CancellationFullRefundDays: 3, CancellationPartialRefundPercent: 50}
+	order := func(status string, daysBefore int) models.Order {
+		return models.Order{
+			Status:        status,
+			TotalPrice:    300000,
+			DeliveryFee:   50000,
+			DepositAmount: 100000,
+			StartDate:     time.Date(2030, time.March, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, daysBefore),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		order   models.Order
+		want    Decision
+		wantErr error
+	}{
+		{
+			name:  "paid well before start",
+			order: order(lifecycle.StatusPaid, 10),
+			want:  Decision{Rule: RuleFull, Percent: 100, DaysBeforeStart: 10, RentalRefund: 300000, DepositRefund: 100000, Amount: 400000},
+		},
+		{
+			name:  "paid exactly on the full refund boundary",
+			order: order(lifecycle.StatusPaid, 3),
+			want:  Decision{Rule: RuleFull, Percent: 100, DaysBeforeStart: 3, RentalRefund: 300000, DepositRefund: 100000, Amount: 400000},
+		},
+		{
+			name:  "paid one day inside the boundary keeps the delivery fee whole",
+			order: order(lifecycle.StatusPaid, 2),
+			want:  Decision{Rule: RulePartial, Percent: 50, DaysBeforeStart: 2, RentalRefund: 175000, DepositRefund: 100000, Amount: 275000},
+		},
+		{
+			name:  "paid on the start date",
+			order: order(lifecycle.StatusPaid, 0),
+			want:  Decision{Rule: RulePartial, Percent: 50, DaysBeforeStart: 0, RentalRefund: 175000, DepositRefund: 100000, Amount: 275000},
+		},
+		{
+			name:  "awaiting approval is always refunded in full",
+			order: order(lifecycle.StatusAwaitingApproval, 0),
+			want:  Decision{Rule: RuleFull, Percent: 100, RentalRefund: 300000, DepositRefund: 100000, Amount: 400000},
+		},
+		{
+			name:    "picked up",
+			order:   order(lifecycle.StatusPickedUp, 10),
+			want:    Decision{Rule: RuleNone},
+			wantErr: ErrAfterPickup,
+		},
+		{
+			name:    "returned",
+			order:   order(lifecycle.StatusReturned, 10),
+			want:    Decision{Rule: RuleNone},
+			wantErr: ErrAfterPickup,
+		},
+		{
+			name:    "not paid yet",
+			order:   order(lifecycle.StatusPending, 10),
+			wantErr: ErrNotPaid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Evaluate(tt.order, shop, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Evaluate error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+// Lokasi: internal/reservation/reservation.go
+package reservation
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrProductNotFound = errors.New("product not found")
+
+// Status perpanjangan sewa
+const (
+	ExtensionPending   = "pending"
+	ExtensionConfirmed = "confirmed"
+	ExtensionCancelled = "cancelled"
+	ExtensionExpired   = "expired"
+)
+
+// Booking adalah satu pemakaian stok produk dalam rentang tanggal tertentu
+type Booking struct {
+	ProductID uuid.UUID
+	StartDate time.Time
+	EndDate   time.Time
+	Quantity  int
+}
+
+// LockProducts mengambil produk dengan SELECT ... FOR UPDATE.
+// Baris dikunci berurutan berdasarkan ID agar dua transaksi yang memesan
+// produk yang sama tidak saling deadlock. Transaksi lain yang ingin memesan
+// produk yang sama akan menunggu sampai transaksi ini commit atau rollback,
+// sehingga pengecekan stok setelahnya selalu melihat pesanan terbaru.
+func LockProducts(tx *gorm.DB, productIDs []uuid.UUID) (map[uuid.UUID]models.Product, error) {
+	return loadProducts(tx, productIDs, true)
+}
+
+// LoadProducts mengambil produk tanpa mengunci, untuk perhitungan yang tidak menyimpan apa pun
+func LoadProducts(tx *gorm.DB, productIDs []uuid.UUID) (map[uuid.UUID]models.Product, error) {
+	return loadProducts(tx, productIDs, false)
+}
+
+func loadProducts(tx *gorm.DB, productIDs []uuid.UUID, lock bool) (map[uuid.UUID]models.Product, error) {
+	ids := make([]string, 0, len(productIDs))
+	seen := make(map[uuid.UUID]bool)
+	for _, id := range productIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id.String())
+	}
+	sort.Strings(ids)
+
+	products := make(map[uuid.UUID]models.Product, len(ids))
+	for _, id := range ids {
+		query := tx
+		if lock {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		var product models.Product
+		if err := query.First(&product, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrProductNotFound
+			}
+			return nil, err
+		}
+		products[product.ID] = product
+	}
+	return products, nil
+}
+
+// Bookings mengambil semua pemakaian stok produk yang beririsan dengan rentang tanggal,
+// termasuk perpanjangan sewa yang masih menunggu pembayaran
+func Bookings(tx *gorm.DB, productID uuid.UUID, startDate, endDate time.Time) ([]Booking, error) {
+	return bookings(tx, []uuid.UUID{productID}, startDate, endDate, uuid.Nil)
+}
+
+// BookingsExcluding sama seperti Bookings tetapi mengabaikan pemakaian milik satu order,
+// dipakai saat order itu sendiri ingin memperpanjang masa sewanya
+func BookingsExcluding(tx *gorm.DB, productID uuid.UUID, startDate, endDate time.Time, orderID uuid.UUID) ([]Booking, error) {
+	return bookings(tx, []uuid.UUID{productID}, startDate, endDate, orderID)
+}
+
+func bookings(tx *gorm.DB, productIDs []uuid.UUID, startDate, endDate time.Time, excludeOrderID uuid.UUID) ([]Booking, error) {
+	var orderBookings []Booking
+	err := tx.Model(&models.OrderItem{}).
+		Select("order_items.product_id, orders.start_date, orders.end_date, order_items.quantity").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id IN ? AND orders.status IN ? AND (orders.start_date, orders.end_date) OVERLAPS (?, ?)", productIDs, lifecycle.HoldingStatuses, startDate, endDate).
+		Where("orders.id <> ?", excludeOrderID).
+		Scan(&orderBookings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Perpanjangan yang belum dibayar ikut menahan stok sampai batas pembayarannya lewat
+	var extensionBookings []Booking
+	err = tx.Model(&models.OrderExtension{}).
+		Select("order_items.product_id, order_extensions.old_end_date AS start_date, order_extensions.new_end_date AS end_date, order_items.quantity").
+		Joins("JOIN order_items ON order_items.order_id = order_extensions.order_id").
+		Where("order_items.product_id IN ? AND order_extensions.status = ? AND (order_extensions.expires_at IS NULL OR order_extensions.expires_at > ?)", productIDs, ExtensionPending, time.Now()).
+		Where("(order_extensions.old_end_date, order_extensions.new_end_date) OVERLAPS (?, ?)", startDate, endDate).
+		Where("order_extensions.order_id <> ?", excludeOrderID).
+		Scan(&extensionBookings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Unit yang sedang dirawat dihitung seperti unit yang disewa. Tanggal akhir
+	// blok perawatan ikut dihitung, sedangkan tanggal akhir sewa tidak.
+	days := Days(startDate, endDate)
+	var maintenanceBookings []Booking
+	err = tx.Model(&models.MaintenanceBlock{}).
+		Select("product_id, start_date, end_date + INTERVAL '1 day' AS end_date, quantity").
+		Where("product_id IN ? AND start_date <= ? AND end_date >= ?", productIDs, days[len(days)-1], days[0]).
+		Scan(&maintenanceBookings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	bookings := append(orderBookings, extensionBookings...)
+	return append(bookings, maintenanceBookings...), nil
+}
+
+// PeakUsage menghitung jumlah unit terbanyak yang dipakai pada satu hari dalam rentang tanggal
+func PeakUsage(bookings []Booking, startDate, endDate time.Time) int {
+	peak := 0
+	for _, day := range Days(startDate, endDate) {
+		used := 0
+		for _, b := range bookings {
+			if occupies(b, day) {
+				used += b.Quantity
+			}
+		}
+		if used > peak {
+			peak = used
+		}
+	}
+	return peak
+}
+
+// Available menghitung sisa stok produk yang bisa disewa pada rentang tanggal
+func Available(tx *gorm.DB, product models.Product, startDate, endDate time.Time) (int, error) {
+	bookings, err := Bookings(tx, product.ID, startDate, endDate)
+	if err != nil {
+		return 0, err
+	}
+	return remaining(product, bookings, startDate, endDate), nil
+}
+
+// AvailableForExtension menghitung sisa stok untuk perpanjangan sewa sebuah order,
+// tanpa menghitung pemakaian order itu sendiri
+func AvailableForExtension(tx *gorm.DB, product models.Product, orderID uuid.UUID, startDate, endDate time.Time) (int, error) {
+	bookings, err := BookingsExcluding(tx, product.ID, startDate, endDate, orderID)
+	if err != nil {
+		return 0, err
+	}
+	return remaining(product, bookings, startDate, endDate), nil
+}
+
+// AvailableByProduct menghitung sisa stok banyak produk sekaligus dengan aturan
+// yang sama seperti Available (puncak pemakaian per hari), tetapi dengan satu
+// query per jenis pemakaian untuk semua produk. Dipakai oleh daftar produk.
+func AvailableByProduct(tx *gorm.DB, products []models.Product, startDate, endDate time.Time) (map[uuid.UUID]int, error) {
+	available := make(map[uuid.UUID]int, len(products))
+	if len(products) == 0 {
+		return available, nil
+	}
+	productIDs := make([]uuid.UUID, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+	all, err := bookings(tx, productIDs, startDate, endDate, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+	byProduct := make(map[uuid.UUID][]Booking)
+	for _, b := range all {
+		byProduct[b.ProductID] = append(byProduct[b.ProductID], b)
+	}
+	for _, product := range products {
+		available[product.ID] = remaining(product, byProduct[product.ID], startDate, endDate)
+	}
+	return available, nil
+}
+
+func remaining(product models.Product, bookings []Booking, startDate, endDate time.Time) int {
+	available := product.Stock - PeakUsage(bookings, startDate, endDate)
+	if available < 0 {
+		available = 0
+	}
+	return available
+}
+
+// DayAvailability adalah jumlah unit produk yang dipakai (disewa atau dirawat) dan
+// yang masih bisa disewa pada satu hari. Closed berarti toko libur sehingga sewa
+// tidak bisa dimulai atau diakhiri pada hari itu.
+type DayAvailability struct {
+	Date      string `json:"date"`
+	Booked    int    `json:"booked"`
+	Available int    `json:"available"`
+	Closed    bool   `json:"closed"`
+}
+
+// Calendar menghitung ketersediaan produk per hari untuk rentang [from, to] (to ikut dihitung).
+// Semua pemakaian diambil dengan satu query lalu dihitung per hari di memori.
+func Calendar(tx *gorm.DB, product models.Product, from, to time.Time) ([]DayAvailability, error) {
+	end := truncateDay(to).AddDate(0, 0, 1)
+	bookings, err := Bookings(tx, product.ID, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	closed, err := ClosedDays(tx, product.ShopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	days := Days(from, end)
+	calendar := make([]DayAvailability, 0, len(days))
+	for _, day := range days {
+		used := 0
+		for _, b := range bookings {
+			if occupies(b, day) {
+				used += b.Quantity
+			}
+		}
+		available := product.Stock - used
+		if available < 0 {
+			available = 0
+		}
+		date := day.Format("2006-01-02")
+		calendar = append(calendar, DayAvailability{Date: date, Booked: used, Available: available, Closed: closed[date]})
+	}
+	return calendar, nil
+}
+
+// ClosedDays mengembalikan hari libur toko (format YYYY-MM-DD) pada rentang [from, to]
+func ClosedDays(tx *gorm.DB, shopID uuid.UUID, from, to time.Time) (map[string]bool, error) {
+	var closures []models.ShopClosure
+	err := tx.Where("shop_id = ? AND start_date <= ? AND end_date >= ?", shopID, truncateDay(to), truncateDay(from)).Find(&closures).Error
+	if err != nil {
+		return nil, err
+	}
+	closed := make(map[string]bool)
+	for _, closure := range closures {
+		for d := truncateDay(closure.StartDate); !d.After(truncateDay(closure.EndDate)); d = d.AddDate(0, 0, 1) {
+			closed[d.Format("2006-01-02")] = true
+		}
+	}
+	return closed, nil
+}
+
+// CheckShopOpen memastikan toko buka pada tanggal pengambilan dan pengembalian barang
+func CheckShopOpen(tx *gorm.DB, shopID uuid.UUID, dates ...time.Time) error {
+	for _, date := range dates {
+		var closure models.ShopClosure
+		err := tx.Where("shop_id = ? AND start_date <= ? AND end_date >= ?", shopID, truncateDay(date), truncateDay(date)).First(&closure).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		message := "the shop is closed on " + date.Format("2006-01-02")
+		if closure.Reason != "" {
+			message += " (" + closure.Reason + ")"
+		}
+		return &ClosedError{Message: message + ", choose another start or end date"}
+	}
+	return nil
+}
+
+// ClosedError dikembalikan jika sewa dimulai atau berakhir pada hari libur toko
+type ClosedError struct {
+	Message string
+}
+
+func (e *ClosedError) Error() string { return e.Message }
+
+// Days mengembalikan setiap hari sewa dalam rentang [startDate, endDate).
+// Sewa di hari yang sama tetap dihitung satu hari.
+func Days(startDate, endDate time.Time) []time.Time {
+	start := truncateDay(startDate)
+	end := truncateDay(endDate)
+	if !end.After(start) {
+		end = start.AddDate(0, 0, 1)
+	}
+	var days []time.Time
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+func occupies(b Booking, day time.Time) bool {
+	start := truncateDay(b.StartDate)
+	end := truncateDay(b.EndDate)
+	if !end.After(start) {
+		end = start.AddDate(0, 0, 1)
+	}
+	return !day.Before(start) && day.Before(end)
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
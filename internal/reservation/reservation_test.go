@@ -0,0 +1,116 @@
+package reservation
+
+import (
+	"testing"
+	"time"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/testdb"
+
+	"github.com/google/uuid"
+)
+
+func day(d int) time.Time {
+	return time.Date(2030, time.January, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPeakUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		bookings []Booking
+		start    time.Time
+		end      time.Time
+		want     int
+	}{
+		{"no bookings", nil, day(1), day(5), 0},
+		{"single booking", []Booking{{StartDate: day(2), EndDate: day(4), Quantity: 3}}, day(1), day(5), 3},
+		{
+			// Dua sewa berurutan tidak pernah dipakai di hari yang sama
+			"back to back bookings do not add up",
+			[]Booking{{StartDate: day(1), EndDate: day(3), Quantity: 2}, {StartDate: day(3), EndDate: day(5), Quantity: 2}},
+			day(1), day(5), 2,
+		},
+		{
+			"overlapping bookings add up on the shared day",
+			[]Booking{{StartDate: day(1), EndDate: day(4), Quantity: 2}, {StartDate: day(3), EndDate: day(5), Quantity: 1}},
+			day(1), day(5), 3,
+		},
+		{
+			"bookings outside the range are ignored",
+			[]Booking{{StartDate: day(10), EndDate: day(12), Quantity: 5}},
+			day(1), day(5), 0,
+		},
+		{
+			"same day rental counts as one day",
+			[]Booking{{StartDate: day(3), EndDate: day(3), Quantity: 4}},
+			day(3), day(3), 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PeakUsage(tt.bookings, tt.start, tt.end); got != tt.want {
+				t.Errorf("PeakUsage() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDays(t *testing.T) {
+	tests := []struct {
+		name  string
+		start time.Time
+		end   time.Time
+		want  int
+	}{
+		{"end date is exclusive", day(1), day(4), 3},
+		{"same day is one day", day(1), day(1), 1},
+		{"time of day is ignored", day(1).Add(15 * time.Hour), day(2).Add(time.Hour), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(Days(tt.start, tt.end)); got != tt.want {
+				t.Errorf("len(Days()) = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvailableByProductMatchesAvailable(t *testing.T) {
+	db := testdb.Open(t)
+	shop := testdb.Shop(t, db)
+	renter := testdb.User(t, db, models.RoleUser)
+	busy := testdb.Product(t, db, shop, 3, 100000)
+	free := testdb.Product(t, db, shop, 2, 100000)
+
+	book := func(product models.Product, start, end time.Time, quantity int, status string) {
+		t.Helper()
+		order := models.Order{ID: uuid.New(), UserID: renter.ID, ShopID: shop.ID, Status: status, StartDate: start, EndDate: end}
+		testdb.Fatal(t, db.Create(&order).Error, "create order")
+		item := models.OrderItem{ID: uuid.New(), OrderID: order.ID, ProductID: product.ID, Quantity: quantity}
+		testdb.Fatal(t, db.Create(&item).Error, "create order item")
+	}
+	start, end := testdb.Date(10), testdb.Date(15)
+	book(busy, testdb.Date(10), testdb.Date(12), 2, lifecycle.StatusPaid)
+	book(busy, testdb.Date(12), testdb.Date(15), 2, lifecycle.StatusPending)
+	book(busy, testdb.Date(11), testdb.Date(13), 3, lifecycle.StatusCancelled)
+	block := models.MaintenanceBlock{ID: uuid.New(), ProductID: busy.ID, Quantity: 1, StartDate: testdb.Date(14), EndDate: testdb.Date(14)}
+	testdb.Fatal(t, db.Create(&block).Error, "create maintenance block")
+
+	got, err := AvailableByProduct(db, []models.Product{busy, free}, start, end)
+	testdb.Fatal(t, err, "AvailableByProduct")
+	for _, product := range []models.Product{busy, free} {
+		want, err := Available(db, product, start, end)
+		testdb.Fatal(t, err, "Available")
+		if got[product.ID] != want {
+			t.Errorf("product %s: AvailableByProduct = %d, Available = %d", product.Name, got[product.ID], want)
+		}
+	}
+	// Hari ke-14: 2 unit disewa + 1 unit dirawat, order cancelled tidak dihitung
+	if got[busy.ID] != 0 {
+		t.Errorf("busy product available = %d, want 0", got[busy.ID])
+	}
+	if got[free.ID] != 2 {
+		t.Errorf("free product available = %d, want 2", got[free.ID])
+	}
+}
@@ -0,0 +1,264 @@
+// Lokasi: internal/scheduler/scheduler.go
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/notification"
+	"sewascaf.com/api/internal/pricing"
+	"sewascaf.com/api/internal/refund"
+	"sewascaf.com/api/internal/reservation"
+	"sewascaf.com/api/internal/tripay"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Clock dipakai agar waktu "sekarang" bisa diganti saat pengujian
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock memakai waktu sistem
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
+
+// Scheduler menjalankan pekerjaan latar belakang secara berkala
+type Scheduler struct {
+	DB              *gorm.DB
+	Clock           Clock
+	PendingOrderTTL time.Duration
+	Refunds         refund.Provider
+}
+
+func New(db *gorm.DB, clock Clock, pendingOrderTTL time.Duration, refunds refund.Provider) *Scheduler {
+	return &Scheduler{
+		DB:              db,
+		Clock:           clock,
+		PendingOrderTTL: pendingOrderTTL,
+		Refunds:         refunds,
+	}
+}
+
+// Run menjalankan semua pekerjaan setiap interval sampai ctx dibatalkan
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.runOnce()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce()
+		}
+	}
+}
+
+func (s *Scheduler) runOnce() {
+	expired, err := s.ExpirePendingOrders()
+	if err != nil {
+		log.Printf("Scheduler: failed to expire pending orders: %v", err)
+	}
+	if expired > 0 {
+		log.Printf("Scheduler: expired %d unpaid orders", expired)
+	}
+
+	overdue, err := s.FlagOverdueOrders()
+	if err != nil {
+		log.Printf("Scheduler: failed to flag overdue orders: %v", err)
+	}
+	if overdue > 0 {
+		log.Printf("Scheduler: updated late fees for %d overdue orders", overdue)
+	}
+
+	extensions, err := s.ExpirePendingExtensions()
+	if err != nil {
+		log.Printf("Scheduler: failed to expire pending extensions: %v", err)
+	}
+	if extensions > 0 {
+		log.Printf("Scheduler: expired %d unpaid extensions", extensions)
+	}
+
+	rejected, err := s.RejectExpiredApprovals(context.Background())
+	if err != nil {
+		log.Printf("Scheduler: failed to reject expired approvals: %v", err)
+	}
+	if rejected > 0 {
+		log.Printf("Scheduler: cancelled %d orders not approved in time", rejected)
+	}
+}
+
+type pendingOrder struct {
+	ID               uuid.UUID
+	PaymentExpiredAt *time.Time
+}
+
+// ExpirePendingOrders mengubah order pending yang melewati batas pembayaran menjadi expired.
+// Batas pembayaran diambil dari expired_time Tripay yang tersimpan, atau dari
+// created_at + PendingOrderTTL jika order belum punya transaksi Tripay.
+// Stok otomatis kembali tersedia karena status expired tidak lagi menahan stok.
+func (s *Scheduler) ExpirePendingOrders() (int, error) {
+	now := s.Clock.Now()
+
+	var candidates []pendingOrder
+	err := s.DB.Table("orders").
+		Select("orders.id, MAX(payments.expired_at) as payment_expired_at").
+		Joins("LEFT JOIN payments ON payments.order_id = orders.id").
+		Where("orders.status = ?", lifecycle.StatusPending).
+		Group("orders.id").
+		Having("MAX(payments.expired_at) < ? OR (MAX(payments.expired_at) IS NULL AND orders.created_at < ?)", now, now.Add(-s.PendingOrderTTL)).
+		Scan(&candidates).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, candidate := range candidates {
+		order := models.Order{ID: candidate.ID, Status: lifecycle.StatusPending}
+		reason := "unpaid for more than " + s.PendingOrderTTL.String()
+		if candidate.PaymentExpiredAt != nil {
+			reason = "payment deadline passed at " + candidate.PaymentExpiredAt.Format(time.RFC3339)
+		}
+
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if err := lifecycle.Transition(tx, &order, lifecycle.StatusExpired, lifecycle.ActorSystem, nil, reason); err != nil {
+				return err
+			}
+			return tx.Model(&models.Payment{}).Where("order_id = ? AND status = ?", order.ID, "UNPAID").Update("status", "EXPIRED").Error
+		})
+		if err != nil {
+			log.Printf("Scheduler: failed to expire order %s: %v", order.ID, err)
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// ExpirePendingExtensions mengakhiri perpanjangan yang belum dibayar setelah
+// expires_at lewat, atau setelah created_at + PendingOrderTTL untuk perpanjangan
+// lama tanpa expires_at, sehingga stok periode tambahannya kembali tersedia.
+func (s *Scheduler) ExpirePendingExtensions() (int, error) {
+	now := s.Clock.Now()
+
+	var extensions []models.OrderExtension
+	err := s.DB.Where("status = ? AND (expires_at < ? OR (expires_at IS NULL AND created_at < ?))", reservation.ExtensionPending, now, now.Add(-s.PendingOrderTTL)).
+		Find(&extensions).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, extension := range extensions {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.OrderExtension{}).Where("id = ? AND status = ?", extension.ID, reservation.ExtensionPending).Update("status", reservation.ExtensionExpired)
+			if result.Error != nil || result.RowsAffected == 0 || extension.Reference == "" {
+				return result.Error
+			}
+			return tx.Model(&models.Payment{}).
+				Where("reference = ? AND purpose = ? AND status = ?", extension.Reference, tripay.PurposeExtension, "UNPAID").
+				Update("status", "EXPIRED").Error
+		})
+		if err != nil {
+			log.Printf("Scheduler: failed to expire extension %s: %v", extension.ID, err)
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// FlagOverdueOrders menandai order yang barangnya belum dikembalikan setelah
+// tanggal akhir sewa dan memperbarui denda keterlambatannya setiap hari.
+// Penyewa dan toko diberi notifikasi saat order pertama kali terlambat.
+// Denda berhenti bertambah setelah toko menandai order sebagai returned.
+func (s *Scheduler) FlagOverdueOrders() (int, error) {
+	now := s.Clock.Now()
+
+	var orders []models.Order
+	err := s.DB.Preload("OrderItems").
+		Where("status = ? AND end_date <= ?", lifecycle.StatusPickedUp, now.Add(-24*time.Hour)).
+		Find(&orders).Error
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, order := range orders {
+		days := pricing.LateDays(order.EndDate, now)
+		if days < 1 || (order.OverdueAt != nil && order.LateDays == days) {
+			continue
+		}
+		fee := pricing.LateFeePerDay(order.OrderItems) * days
+
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			updates := map[string]interface{}{"late_days": days, "late_fee": fee}
+			firstFlag := order.OverdueAt == nil
+			if firstFlag {
+				updates["overdue_at"] = now
+			}
+			result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, lifecycle.StatusPickedUp).Updates(updates)
+			if result.Error != nil || result.RowsAffected == 0 || !firstFlag {
+				return result.Error
+			}
+			message := fmt.Sprintf("Order %s was due back on %s and has not been returned. Late fee so far: Rp%d for %d day(s), increasing daily until the items are returned.",
+				order.ID, order.EndDate.Format("2006-01-02"), fee, days)
+			return notification.NotifyOrderParties(tx, order, notification.TypeOrderOverdue, "Rental is overdue", message)
+		})
+		if err != nil {
+			log.Printf("Scheduler: failed to flag overdue order %s: %v", order.ID, err)
+			continue
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// RejectExpiredApprovals membatalkan order yang tidak disetujui toko sampai
+// approval_deadline. Penyewa mendapat refund penuh dan notifikasi.
+func (s *Scheduler) RejectExpiredApprovals(ctx context.Context) (int, error) {
+	now := s.Clock.Now()
+
+	var orders []models.Order
+	err := s.DB.Where("status = ? AND approval_deadline < ?", lifecycle.StatusAwaitingApproval, now).Find(&orders).Error
+	if err != nil {
+		return 0, err
+	}
+
+	rejected := 0
+	for _, order := range orders {
+		var pendingRefund *models.Refund
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			reason := "not approved by the shop before " + order.ApprovalDeadline.Format(time.RFC3339)
+			if err := lifecycle.Transition(tx, &order, lifecycle.StatusCancelled, lifecycle.ActorSystem, nil, reason); err != nil {
+				return err
+			}
+			var err error
+			pendingRefund, err = refund.Create(tx, order, refund.Full(order), reason, s.Refunds.Name())
+			if err != nil {
+				return err
+			}
+			message := fmt.Sprintf("The shop did not approve order %s in time. The order has been cancelled and your payment will be refunded.", order.ID)
+			return notification.Notify(tx, order.UserID, notification.TypeOrderRejected, "Order not approved", message, &order.ID)
+		})
+		if err != nil {
+			log.Printf("Scheduler: failed to reject order %s: %v", order.ID, err)
+			continue
+		}
+		if pendingRefund != nil {
+			if err := refund.Execute(ctx, s.DB, s.Refunds, pendingRefund); err != nil {
+				log.Printf("Scheduler: failed to process refund %s for order %s: %v", pendingRefund.ID, order.ID, err)
+			}
+		}
+		rejected++
+	}
+	return rejected, nil
+}
@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/refund"
+	"sewascaf.com/api/internal/reservation"
+	"sewascaf.com/api/internal/testdb"
+
+	"github.com/google/uuid"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c fakeClock) Now() time.Time { return c.now }
+
+func TestExpirePendingOrders(t *testing.T) {
+	db := testdb.Open(t)
+	shop := testdb.Shop(t, db)
+	renter := testdb.User(t, db, models.RoleUser)
+	now := time.Now()
+	ttl := time.Hour
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name        string
+		createdAt   time.Time
+		paymentExp  *time.Time
+		status      string
+		wantExpired bool
+	}{
+		{"no payment, older than ttl", now.Add(-2 * ttl), nil, lifecycle.StatusPending, true},
+		{"no payment, within ttl", now.Add(-ttl / 2), nil, lifecycle.StatusPending, false},
+		{"payment deadline passed", now.Add(-ttl / 2), &past, lifecycle.StatusPending, true},
+		{"payment deadline ahead overrides ttl", now.Add(-2 * ttl), &future, lifecycle.StatusPending, false},
+		{"paid order is left alone", now.Add(-2 * ttl), nil, lifecycle.StatusPaid, false},
+	}
+
+	orders := make([]models.Order, len(tests))
+	for i, tt := range tests {
+		orders[i] = testdb.Order(t, db, shop, renter, tt.status)
+		testdb.Fatal(t, db.Model(&orders[i]).Update("created_at", tt.createdAt).Error, "set created_at")
+		if tt.paymentExp != nil {
+			payment := testdb.Payment(t, db, orders[i], "UNPAID")
+			testdb.Fatal(t, db.Model(&payment).Update("expired_at", tt.paymentExp).Error, "set expired_at")
+		}
+	}
+
+	s := New(db, fakeClock{now}, ttl, refund.NewFakeProvider())
+	expired, err := s.ExpirePendingOrders()
+	testdb.Fatal(t, err, "ExpirePendingOrders")
+
+	want := 0
+	for i, tt := range tests {
+		var got models.Order
+		testdb.Fatal(t, db.First(&got, "id = ?", orders[i].ID).Error, "reload order")
+		wantStatus := tt.status
+		if tt.wantExpired {
+			wantStatus = lifecycle.StatusExpired
+			want++
+		}
+		if got.Status != wantStatus {
+			t.Errorf("%s: status = %s, want %s", tt.name, got.Status, wantStatus)
+		}
+	}
+	if expired != want {
+		t.Errorf("expired %d orders, want %d", expired, want)
+	}
+
+	var unpaid int64
+	db.Model(&models.Payment{}).Where("order_id = ? AND status = ?", orders[2].ID, "UNPAID").Count(&unpaid)
+	if unpaid != 0 {
+		t.Errorf("payment of expired order is still UNPAID")
+	}
+}
+
+// TestExpirePendingOrdersFollowsClock memastikan batas waktu dihitung dari Clock,
+// bukan dari waktu sistem
+func TestExpirePendingOrdersFollowsClock(t *testing.T) {
+	db := testdb.Open(t)
+	order := testdb.Order(t, db, testdb.Shop(t, db), testdb.User(t, db, models.RoleUser), lifecycle.StatusPending)
+
+	clock := &fakeClock{now: order.CreatedAt.Add(30 * time.Minute)}
+	s := New(db, clock, time.Hour, refund.NewFakeProvider())
+	if n, err := s.ExpirePendingOrders(); err != nil || n != 0 {
+		t.Fatalf("before ttl: expired %d, err %v; want 0, nil", n, err)
+	}
+
+	clock.now = order.CreatedAt.Add(2 * time.Hour)
+	if n, err := s.ExpirePendingOrders(); err != nil || n != 1 {
+		t.Fatalf("after ttl: expired %d, err %v; want 1, nil", n, err)
+	}
+}
+
+func TestExpirePendingExtensions(t *testing.T) {
+	db := testdb.Open(t)
+	shop := testdb.Shop(t, db)
+	renter := testdb.User(t, db, models.RoleUser)
+	now := time.Now()
+	ttl := time.Hour
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name       string
+		createdAt  time.Time
+		expiresAt  *time.Time
+		status     string
+		wantStatus string
+	}{
+		{"deadline passed", now.Add(-ttl / 2), &past, reservation.ExtensionPending, reservation.ExtensionExpired},
+		{"deadline ahead", now.Add(-2 * ttl), &future, reservation.ExtensionPending, reservation.ExtensionPending},
+		{"no deadline, older than ttl", now.Add(-2 * ttl), nil, reservation.ExtensionPending, reservation.ExtensionExpired},
+		{"no deadline, within ttl", now.Add(-ttl / 2), nil, reservation.ExtensionPending, reservation.ExtensionPending},
+		{"confirmed extension is left alone", now.Add(-2 * ttl), &past, reservation.ExtensionConfirmed, reservation.ExtensionConfirmed},
+	}
+
+	order := testdb.Order(t, db, shop, renter, lifecycle.StatusPaid)
+	extensions := make([]models.OrderExtension, len(tests))
+	for i, tt := range tests {
+		extensions[i] = models.OrderExtension{
+			ID:         uuid.New(),
+			OrderID:    order.ID,
+			OldEndDate: order.EndDate,
+			NewEndDate: order.EndDate.AddDate(0, 0, 2),
+			Amount:     20000,
+			Status:     tt.status,
+			Reference:  "T-EXT-" + uuid.NewString()[:8],
+			ExpiresAt:  tt.expiresAt,
+			CreatedAt:  tt.createdAt,
+		}
+		testdb.Fatal(t, db.Create(&extensions[i]).Error, "create extension")
+	}
+	payment := models.Payment{ID: uuid.New(), OrderID: order.ID, Reference: extensions[0].Reference, Amount: 20000, Status: "UNPAID", Purpose: "extension"}
+	testdb.Fatal(t, db.Create(&payment).Error, "create extension payment")
+
+	s := New(db, fakeClock{now}, ttl, refund.NewFakeProvider())
+	expired, err := s.ExpirePendingExtensions()
+	testdb.Fatal(t, err, "ExpirePendingExtensions")
+	if expired != 2 {
+		t.Errorf("expired %d extensions, want 2", expired)
+	}
+	for i, tt := range tests {
+		var got models.OrderExtension
+		testdb.Fatal(t, db.First(&got, "id = ?", extensions[i].ID).Error, "reload extension")
+		if got.Status != tt.wantStatus {
+			t.Errorf("%s: status = %s, want %s", tt.name, got.Status, tt.wantStatus)
+		}
+	}
+	testdb.Fatal(t, db.First(&payment, "id = ?", payment.ID).Error, "reload payment")
+	if payment.Status != "EXPIRED" {
+		t.Errorf("payment of expired extension = %s, want EXPIRED", payment.Status)
+	}
+}
+
+func TestRejectExpiredApprovals(t *testing.T) {
+	db := testdb.Open(t)
+	shop := testdb.Shop(t, db)
+	renter := testdb.User(t, db, models.RoleUser)
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		deadline     time.Time
+		wantRejected bool
+	}{
+		{"deadline passed", now.Add(-time.Minute), true},
+		{"deadline ahead", now.Add(time.Hour), false},
+	}
+
+	orders := make([]models.Order, len(tests))
+	for i, tt := range tests {
+		orders[i] = testdb.Order(t, db, shop, renter, lifecycle.StatusAwaitingApproval)
+		testdb.Fatal(t, db.Model(&orders[i]).Update("approval_deadline", tt.deadline).Error, "set deadline")
+		testdb.Payment(t, db, orders[i], "PAID")
+	}
+
+	refunds := refund.NewFakeProvider()
+	s := New(db, fakeClock{now}, time.Hour, refunds)
+	rejected, err := s.RejectExpiredApprovals(context.Background())
+	testdb.Fatal(t, err, "RejectExpiredApprovals")
+	if rejected != 1 {
+		t.Errorf("rejected %d orders, want 1", rejected)
+	}
+
+	for i, tt := range tests {
+		var got models.Order
+		testdb.Fatal(t, db.First(&got, "id = ?", orders[i].ID).Error, "reload order")
+		// FakeProvider langsung berhasil, jadi order yang ditolak berakhir refunded
+		wantStatus, wantRefunds := lifecycle.StatusAwaitingApproval, int64(0)
+		if tt.wantRejected {
+			wantStatus, wantRefunds = lifecycle.StatusRefunded, 1
+		}
+		if got.Status != wantStatus {
+			t.Errorf("%s: status = %s, want %s", tt.name, got.Status, wantStatus)
+		}
+		var succeeded int64
+		db.Model(&models.Refund{}).Where("order_id = ? AND status = ?", orders[i].ID, refund.StatusSucceeded).Count(&succeeded)
+		if succeeded != wantRefunds {
+			t.Errorf("%s: %d succeeded refunds, want %d", tt.name, succeeded, wantRefunds)
+		}
+	}
+
+	requests := refunds.Requests()
+	if len(requests) != 1 || requests[0].OrderID != orders[0].ID || requests[0].Amount != orders[0].ChargeAmount() {
+		t.Errorf("refund requests = %+v, want one full refund for %s", requests, orders[0].ID)
+	}
+}
@@ -0,0 +1,376 @@
+// Lokasi: internal/session/session.go
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid or expired token")
+	ErrSessionRevoked = errors.New("session has been revoked")
+	ErrTokenReused    = errors.New("refresh token was already used, the session has been revoked")
+)
+
+// ChallengeTTL adalah masa berlaku challenge token login dua langkah
+const ChallengeTTL = 5 * time.Minute
+
+// challengeType menandai challenge token agar tidak bisa dipakai sebagai access token
+const challengeType = "2fa_challenge"
+
+// ChallengePurpose adalah kegunaan AuthToken yang menyimpan jti challenge token
+// sehingga setiap challenge hanya bisa dipakai sekali
+const ChallengePurpose = "login_challenge"
+
+// Tokens adalah pasangan access token dan refresh token yang dikirim ke client
+type Tokens struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int       `json:"expires_in"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        uuid.UUID `json:"session_id"`
+}
+
+// Claims adalah isi access token yang sudah diverifikasi. ShopID hanya terisi
+// untuk user dengan role pengusaha. TwoFactor bernilai true jika sesi ini sudah
+// melewati 2FA, bukan sekadar user-nya mengaktifkan 2FA.
+type Claims struct {
+	UserID    string
+	SessionID uuid.UUID
+	Role      string
+	ShopID    *uuid.UUID
+	TwoFactor bool
+}
+
+// Issuer membuat access token berumur pendek dan refresh token yang disimpan
+// di server (dalam bentuk hash) per sesi login. Refresh token dirotasi setiap
+// dipakai; token lama yang dipakai ulang dianggap dicuri dan sesinya dicabut.
+type Issuer struct {
+	DB         *gorm.DB
+	Secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func NewIssuer(db *gorm.DB, jwtSecret string, accessTTL, refreshTTL time.Duration) *Issuer {
+	return &Issuer{
+		DB:         db,
+		Secret:     []byte(jwtSecret),
+		AccessTTL:  accessTTL,
+		RefreshTTL: refreshTTL,
+	}
+}
+
+// Start membuat sesi baru untuk user yang berhasil login. twoFactor menandai
+// login yang sudah menyelesaikan langkah kedua 2FA.
+func (i *Issuer) Start(user models.User, userAgent, ip string, twoFactor bool) (Tokens, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+	now := time.Now()
+	session := models.Session{
+		ID:               uuid.New(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        userAgent,
+		IPAddress:        ip,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(i.RefreshTTL),
+	}
+	if twoFactor {
+		session.TwoFactorVerifiedAt = &now
+	}
+	if err := i.DB.Create(&session).Error; err != nil {
+		return Tokens{}, err
+	}
+	return i.tokens(user, session, refreshToken)
+}
+
+// Refresh menukar refresh token dengan pasangan token baru di sesi yang sama
+func (i *Issuer) Refresh(refreshToken, userAgent, ip string) (Tokens, error) {
+	hash := hashToken(refreshToken)
+	var tokens Tokens
+	err := i.DB.Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("refresh_token_hash = ?", hash).First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if session.RevokedAt != nil {
+			return ErrSessionRevoked
+		}
+		now := time.Now()
+		if now.After(session.ExpiresAt) {
+			return ErrInvalidToken
+		}
+
+		var user models.User
+		if err := tx.First(&user, "id = ?", session.UserID).Error; err != nil {
+			return ErrInvalidToken
+		}
+
+		newToken, err := newRefreshToken()
+		if err != nil {
+			return err
+		}
+		session.PreviousTokenHash = session.RefreshTokenHash
+		session.RefreshTokenHash = hashToken(newToken)
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(i.RefreshTTL)
+		if userAgent != "" {
+			session.UserAgent = userAgent
+		}
+		session.IPAddress = ip
+		err = tx.Model(&session).Updates(map[string]interface{}{
+			"previous_token_hash": session.PreviousTokenHash,
+			"refresh_token_hash":  session.RefreshTokenHash,
+			"last_used_at":        session.LastUsedAt,
+			"expires_at":          session.ExpiresAt,
+			"user_agent":          session.UserAgent,
+			"ip_address":          session.IPAddress,
+		}).Error
+		if err != nil {
+			return err
+		}
+		tokens, err = i.tokens(user, session, newToken)
+		return err
+	})
+	if errors.Is(err, ErrInvalidToken) {
+		// Refresh token yang sudah dirotasi dipakai lagi: cabut sesinya
+		result := i.DB.Model(&models.Session{}).
+			Where("previous_token_hash = ? AND revoked_at IS NULL", hash).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return Tokens{}, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return Tokens{}, ErrTokenReused
+		}
+	}
+	return tokens, err
+}
+
+// AccessToken membuat access token baru untuk sesi yang sudah ada, misalnya
+// setelah data user di token berubah
+func (i *Issuer) AccessToken(user models.User, sessionID uuid.UUID) (string, error) {
+	var session models.Session
+	if err := i.DB.Select("id", "two_factor_verified_at").First(&session, "id = ?", sessionID).Error; err != nil {
+		return "", err
+	}
+	return i.sign(user, session)
+}
+
+// MarkTwoFactor menandai sesi sudah melewati 2FA, misalnya setelah user
+// mengonfirmasi kode saat mengaktifkan 2FA
+func (i *Issuer) MarkTwoFactor(sessionID uuid.UUID) error {
+	return i.DB.Model(&models.Session{}).Where("id = ?", sessionID).Update("two_factor_verified_at", time.Now()).Error
+}
+
+// Parse memverifikasi tanda tangan dan masa berlaku access token
+func (i *Issuer) Parse(tokenString string) (Claims, error) {
+	token, err := jwt.Parse(tokenString, i.key)
+	if err != nil || !token.Valid {
+		return Claims{}, ErrInvalidToken
+	}
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || mapClaims["typ"] != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	userID, _ := mapClaims["sub"].(string)
+	sid, _ := mapClaims["sid"].(string)
+	sessionID, err := uuid.Parse(sid)
+	if userID == "" || err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	claims := Claims{UserID: userID, SessionID: sessionID}
+	claims.Role, _ = mapClaims["role"].(string)
+	claims.TwoFactor, _ = mapClaims["tfa"].(bool)
+	if shopID, ok := mapClaims["shop_id"].(string); ok {
+		if id, err := uuid.Parse(shopID); err == nil {
+			claims.ShopID = &id
+		}
+	}
+	return claims, nil
+}
+
+// Challenge membuat token berumur pendek untuk langkah kedua login user
+// yang memakai 2FA. Token ini tidak memberi akses ke endpoint lain. jti-nya
+// disimpan sebagai AuthToken agar bisa dihabiskan oleh ConsumeChallenge.
+func (i *Issuer) Challenge(user models.User) (string, error) {
+	now := time.Now()
+	jti := uuid.NewString()
+	err := i.DB.Create(&models.AuthToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Purpose:   ChallengePurpose,
+		TokenHash: hashToken(jti),
+		ExpiresAt: now.Add(ChallengeTTL),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		"sub": user.ID.String(),
+		"jti": jti,
+		"typ": challengeType,
+		"iat": now.Unix(),
+		"exp": now.Add(ChallengeTTL).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.Secret)
+}
+
+// ParseChallenge memverifikasi challenge token dan mengembalikan ID user serta jti-nya
+func (i *Issuer) ParseChallenge(tokenString string) (uuid.UUID, string, error) {
+	token, err := jwt.Parse(tokenString, i.key)
+	if err != nil || !token.Valid {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || mapClaims["typ"] != challengeType {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	sub, _ := mapClaims["sub"].(string)
+	jti, _ := mapClaims["jti"].(string)
+	userID, err := uuid.Parse(sub)
+	if err != nil || jti == "" {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	return userID, jti, nil
+}
+
+// ConsumeChallenge menandai challenge token terpakai. Dipanggil di transaksi yang
+// sama dengan verifikasi kode, jadi challenge hanya habis jika kodenya benar dan
+// request kedua dengan challenge yang sama ditolak.
+func ConsumeChallenge(tx *gorm.DB, userID uuid.UUID, jti string) error {
+	now := time.Now()
+	result := tx.Model(&models.AuthToken{}).
+		Where("token_hash = ? AND purpose = ? AND user_id = ? AND used_at IS NULL AND expires_at > ?", hashToken(jti), ChallengePurpose, userID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Active mengecek bahwa sesi belum dicabut dan belum kedaluwarsa
+func (i *Issuer) Active(sessionID uuid.UUID) error {
+	var session models.Session
+	if err := i.DB.Select("id", "revoked_at", "expires_at").First(&session, "id = ?", sessionID).Error; err != nil {
+		return ErrSessionRevoked
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// Revoke mencabut satu sesi milik user
+func (i *Issuer) Revoke(userID string, sessionID uuid.UUID) (bool, error) {
+	result := i.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAll mencabut semua sesi aktif user dan mengembalikan jumlahnya
+func (i *Issuer) RevokeAll(userID string) (int64, error) {
+	result := i.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// RevokeOthers mencabut semua sesi aktif user kecuali sesi keep
+func (i *Issuer) RevokeOthers(userID string, keep uuid.UUID) (int64, error) {
+	result := i.DB.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keep).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// ActiveSessions menampilkan sesi user yang masih bisa dipakai
+func (i *Issuer) ActiveSessions(userID string) ([]models.Session, error) {
+	sessions := make([]models.Session, 0)
+	err := i.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (i *Issuer) tokens(user models.User, session models.Session, refreshToken string) (Tokens, error) {
+	accessToken, err := i.sign(user, session)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(i.AccessTTL.Seconds()),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+		SessionID:        session.ID,
+	}, nil
+}
+
+// sign membuat access token berisi user, sesi, role, status 2FA sesi, dan toko
+// milik user. tfa hanya true jika user memakai 2FA dan sesi ini sudah melewatinya.
+func (i *Issuer) sign(user models.User, session models.Session) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":  user.ID.String(),
+		"sid":  session.ID.String(),
+		"role": user.Role,
+		"tfa":  user.TwoFactorEnabledAt != nil && session.TwoFactorVerifiedAt != nil,
+		"iat":  now.Unix(),
+		"exp":  now.Add(i.AccessTTL).Unix(),
+	}
+	if user.Role == models.RolePengusaha {
+		var shop models.Shop
+		if err := i.DB.Select("id").Where("user_id = ?", user.ID).First(&shop).Error; err == nil {
+			claims["shop_id"] = shop.ID.String()
+		}
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.Secret)
+}
+
+// key hanya menerima token yang ditandatangani dengan HMAC
+func (i *Issuer) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errors.New("unexpected signing method")
+	}
+	return i.Secret, nil
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken menyimpan refresh token sebagai SHA-256 agar kebocoran database
+// tidak langsung membocorkan token yang masih berlaku
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,127 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/testdb"
+)
+
+func newTestIssuer(t *testing.T) (*Issuer, models.User) {
+	t.Helper()
+	db := testdb.Open(t)
+	return NewIssuer(db, "test-secret", time.Minute, time.Hour), testdb.User(t, db, models.RoleUser)
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	issuer, user := newTestIssuer(t)
+	first, err := issuer.Start(user, "test", "127.0.0.1", false)
+	testdb.Fatal(t, err, "Start")
+
+	second, err := issuer.Refresh(first.RefreshToken, "test", "127.0.0.1")
+	testdb.Fatal(t, err, "Refresh")
+	if second.SessionID != first.SessionID {
+		t.Errorf("refresh moved to session %s, want %s", second.SessionID, first.SessionID)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Errorf("refresh token was not rotated")
+	}
+
+	claims, err := issuer.Parse(second.AccessToken)
+	testdb.Fatal(t, err, "Parse")
+	if claims.UserID != user.ID.String() || claims.SessionID != first.SessionID {
+		t.Errorf("claims = %+v, want user %s in session %s", claims, user.ID, first.SessionID)
+	}
+	testdb.Fatal(t, issuer.Active(first.SessionID), "Active")
+}
+
+// TestRefreshReuseRevokesSession memakai lagi refresh token yang sudah dirotasi:
+// seluruh sesi dicabut sehingga token terbaru pun tidak berlaku lagi
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	issuer, user := newTestIssuer(t)
+	first, err := issuer.Start(user, "test", "127.0.0.1", false)
+	testdb.Fatal(t, err, "Start")
+	second, err := issuer.Refresh(first.RefreshToken, "test", "127.0.0.1")
+	testdb.Fatal(t, err, "Refresh")
+
+	if _, err := issuer.Refresh(first.RefreshToken, "attacker", "10.0.0.1"); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("reused refresh error = %v, want %v", err, ErrTokenReused)
+	}
+
+	var session models.Session
+	testdb.Fatal(t, issuer.DB.First(&session, "id = ?", first.SessionID).Error, "reload session")
+	if session.RevokedAt == nil {
+		t.Errorf("session was not revoked after refresh token reuse")
+	}
+	if err := issuer.Active(first.SessionID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Active error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if _, err := issuer.Refresh(second.RefreshToken, "test", "127.0.0.1"); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("refresh with the latest token error = %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestRefreshRejectsUnknownToken(t *testing.T) {
+	issuer, _ := newTestIssuer(t)
+	if _, err := issuer.Refresh("not-a-token", "test", "127.0.0.1"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRevokeEndsSession(t *testing.T) {
+	issuer, user := newTestIssuer(t)
+	tokens, err := issuer.Start(user, "test", "127.0.0.1", false)
+	testdb.Fatal(t, err, "Start")
+	other, err := issuer.Start(user, "other device", "127.0.0.2", false)
+	testdb.Fatal(t, err, "Start other")
+
+	revoked, err := issuer.Revoke(user.ID.String(), tokens.SessionID)
+	testdb.Fatal(t, err, "Revoke")
+	if !revoked {
+		t.Fatalf("Revoke reported no session revoked")
+	}
+	if err := issuer.Active(tokens.SessionID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Active after logout error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if _, err := issuer.Refresh(tokens.RefreshToken, "test", "127.0.0.1"); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Refresh after logout error = %v, want %v", err, ErrSessionRevoked)
+	}
+	testdb.Fatal(t, issuer.Active(other.SessionID), "Active on the other device")
+
+	revoked, err = issuer.Revoke(user.ID.String(), tokens.SessionID)
+	testdb.Fatal(t, err, "Revoke again")
+	if revoked {
+		t.Errorf("second Revoke reported a session revoked")
+	}
+}
+
+func TestRevokeIgnoresOtherUsersSession(t *testing.T) {
+	issuer, user := newTestIssuer(t)
+	tokens, err := issuer.Start(user, "test", "127.0.0.1", false)
+	testdb.Fatal(t, err, "Start")
+	stranger := testdb.User(t, issuer.DB, models.RoleUser)
+
+	revoked, err := issuer.Revoke(stranger.ID.String(), tokens.SessionID)
+	testdb.Fatal(t, err, "Revoke")
+	if revoked {
+		t.Errorf("another user revoked the session")
+	}
+	testdb.Fatal(t, issuer.Active(tokens.SessionID), "Active")
+}
+
+func TestActiveRejectsExpiredSession(t *testing.T) {
+	issuer, user := newTestIssuer(t)
+	tokens, err := issuer.Start(user, "test", "127.0.0.1", false)
+	testdb.Fatal(t, err, "Start")
+	err = issuer.DB.Model(&models.Session{}).Where("id = ?", tokens.SessionID).Update("expires_at", time.Now().Add(-time.Minute)).Error
+	testdb.Fatal(t, err, "expire session")
+
+	if err := issuer.Active(tokens.SessionID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Active error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if _, err := issuer.Refresh(tokens.RefreshToken, "test", "127.0.0.1"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh error = %v, want %v", err, ErrInvalidToken)
+	}
+}
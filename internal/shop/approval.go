@@ -0,0 +1,170 @@
+// Lokasi: internal/shop/approval.go
+package shop
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/middleware"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/notification"
+	"sewascaf.com/api/internal/refund"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errApprovalNoUser = errors.New("user ID not found")
+	errShopNotFound   = errors.New("shop not found for this user")
+	errOrderNotOwned  = errors.New("order not found or you do not have permission to edit it")
+)
+
+type ApprovalSettingsPayload struct {
+	RequiresApproval    *bool `json:"requires_approval" binding:"required"`
+	ApprovalWindowHours int   `json:"approval_window_hours" binding:"omitempty,min=1,max=168"`
+}
+
+// UpdateApprovalSettings mengaktifkan atau mematikan mode persetujuan order.
+// Jika aktif, order yang sudah dibayar menunggu persetujuan toko sampai batas waktu.
+func (h *Handler) UpdateApprovalSettings(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+
+	var payload ApprovalSettingsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{"requires_approval": *payload.RequiresApproval}
+	if payload.ApprovalWindowHours > 0 {
+		updates["approval_window_hours"] = payload.ApprovalWindowHours
+	}
+	result := h.DB.Model(&models.Shop{}).Where("id = ?", shopID).Updates(updates)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval settings"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Approval settings updated successfully"})
+}
+
+// ApproveOrder menyetujui order yang menunggu persetujuan sehingga menjadi paid
+func (h *Handler) ApproveOrder(c *gin.Context) {
+	order, err := h.decideApproval(c, lifecycle.StatusPaid, "approved by shop", nil)
+	if err != nil {
+		h.respondApprovalError(c, err)
+		return
+	}
+	message := fmt.Sprintf("Your order %s has been approved by the shop.", order.ID)
+	if err := notification.Notify(h.DB, order.UserID, notification.TypeOrderApproved, "Order approved", message, &order.ID); err != nil {
+		log.Printf("Failed to notify renter about approval of order %s: %v", order.ID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Order approved successfully"})
+}
+
+type RejectOrderPayload struct {
+	Reason string `json:"reason"`
+}
+
+// RejectOrder menolak order yang menunggu persetujuan. Order dibatalkan, stoknya
+// kembali tersedia, dan seluruh pembayaran dikembalikan ke penyewa. Refund dicatat
+// di transaksi yang sama dengan pembatalan, jadi order tidak pernah batal tanpa refund.
+func (h *Handler) RejectOrder(c *gin.Context) {
+	var payload RejectOrderPayload
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	reason := "rejected by shop"
+	if payload.Reason != "" {
+		reason += ": " + payload.Reason
+	}
+
+	var pendingRefund *models.Refund
+	order, err := h.decideApproval(c, lifecycle.StatusCancelled, reason, func(tx *gorm.DB, order models.Order) error {
+		var err error
+		pendingRefund, err = refund.Create(tx, order, refund.Full(order), reason, h.Refunds.Name())
+		return err
+	})
+	if err != nil {
+		h.respondApprovalError(c, err)
+		return
+	}
+
+	message := fmt.Sprintf("Your order %s was rejected by the shop and your payment will be refunded.", order.ID)
+	if err := notification.Notify(h.DB, order.UserID, notification.TypeOrderRejected, "Order rejected", message, &order.ID); err != nil {
+		log.Printf("Failed to notify renter about rejection of order %s: %v", order.ID, err)
+	}
+	if pendingRefund != nil {
+		if err := refund.Execute(c.Request.Context(), h.DB, h.Refunds, pendingRefund); err != nil {
+			log.Printf("Failed to process refund %s for order %s: %v", pendingRefund.ID, order.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Order rejected successfully", "refund": pendingRefund})
+}
+
+// decideApproval memindahkan order awaiting_approval milik toko si user ke status to.
+// then, jika ada, dijalankan di transaksi yang sama setelah status berubah.
+func (h *Handler) decideApproval(c *gin.Context, to, reason string, then func(tx *gorm.DB, order models.Order) error) (models.Order, error) {
+	vendorID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		return models.Order{}, errApprovalNoUser
+	}
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		return models.Order{}, errShopNotFound
+	}
+
+	var order models.Order
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND shop_id = ?", c.Param("orderId"), shopID).First(&order).Error; err != nil {
+			return errOrderNotOwned
+		}
+		if to == lifecycle.StatusPaid {
+			if err := lifecycle.Approve(tx, &order, &vendorID, time.Now()); err != nil {
+				return err
+			}
+		} else {
+			if order.Status != lifecycle.StatusAwaitingApproval {
+				return lifecycle.ErrNotAwaiting
+			}
+			if err := lifecycle.Transition(tx, &order, to, lifecycle.ActorVendor, &vendorID, reason); err != nil {
+				return err
+			}
+		}
+		if then != nil {
+			return then(tx, order)
+		}
+		return nil
+	})
+	return order, err
+}
+
+func (h *Handler) respondApprovalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, lifecycle.ErrNotAwaiting), errors.Is(err, lifecycle.ErrApprovalExpired), errors.Is(err, lifecycle.ErrStatusChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, refund.ErrNoPaidPayment), errors.Is(err, refund.ErrAlreadyRefunds):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order cannot be rejected because its refund could not be recorded", "details": err.Error()})
+	case errors.Is(err, errApprovalNoUser), errors.Is(err, errShopNotFound), errors.Is(err, errOrderNotOwned):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order", "details": err.Error()})
+	}
+}
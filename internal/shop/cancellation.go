@@ -0,0 +1,65 @@
+// Lokasi: internal/shop/cancellation.go
+package shop
+
+import (
+	"net/http"
+
+	"sewascaf.com/api/internal/middleware"
+	"sewascaf.com/api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CancellationPolicy struct {
+	FullRefundDays       int `json:"full_refund_days"`
+	PartialRefundPercent int `json:"partial_refund_percent"`
+}
+
+type UpdateCancellationPolicyPayload struct {
+	FullRefundDays       *int `json:"full_refund_days" binding:"required,min=0,max=365"`
+	PartialRefundPercent *int `json:"partial_refund_percent" binding:"required,min=0,max=100"`
+}
+
+// GetCancellationPolicy menampilkan kebijakan pembatalan sebuah toko untuk halaman checkout
+func (h *Handler) GetCancellationPolicy(c *gin.Context) {
+	var shop models.Shop
+	if err := h.DB.Where("id = ?", c.Param("shopId")).First(&shop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+	c.JSON(http.StatusOK, CancellationPolicy{
+		FullRefundDays:       shop.CancellationFullRefundDays,
+		PartialRefundPercent: shop.CancellationPartialRefundPercent,
+	})
+}
+
+// UpdateCancellationPolicy mengatur kebijakan pembatalan toko: refund penuh sampai
+// full_refund_days hari sebelum tanggal mulai, lalu partial_refund_percent persen
+// dari harga sewa sampai barang diambil
+func (h *Handler) UpdateCancellationPolicy(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+
+	var payload UpdateCancellationPolicyPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	result := h.DB.Model(&models.Shop{}).Where("id = ?", shopID).Updates(map[string]interface{}{
+		"cancellation_full_refund_days":       *payload.FullRefundDays,
+		"cancellation_partial_refund_percent": *payload.PartialRefundPercent,
+	})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cancellation policy"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+	c.JSON(http.StatusOK, CancellationPolicy{FullRefundDays: *payload.FullRefundDays, PartialRefundPercent: *payload.PartialRefundPercent})
+}
@@ -0,0 +1,99 @@
+// Lokasi: internal/shop/closure.go
+package shop
+
+import (
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/middleware"
+	"sewascaf.com/api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ShopClosurePayload struct {
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date"`
+	Reason    string `json:"reason"`
+}
+
+// GetShopClosures menampilkan hari libur toko yang belum lewat
+func (h *Handler) GetShopClosures(c *gin.Context) {
+	today := time.Now().Format("2006-01-02")
+	var closures []models.ShopClosure
+	if err := h.DB.Where("shop_id = ? AND end_date >= ?", c.Param("shopId"), today).Order("start_date ASC").Find(&closures).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shop closures"})
+		return
+	}
+	if closures == nil {
+		closures = make([]models.ShopClosure, 0)
+	}
+	c.JSON(http.StatusOK, closures)
+}
+
+// CreateShopClosure menambahkan hari libur toko. Tanpa end_date, libur hanya satu hari.
+// Order yang sudah ada tidak berubah; toko perlu mengatur ulang jadwal dengan penyewanya.
+func (h *Handler) CreateShopClosure(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+
+	var payload ShopClosurePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	startDate, err := time.Parse("2006-01-02", payload.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format, use YYYY-MM-DD"})
+		return
+	}
+	endDate := startDate
+	if payload.EndDate != "" {
+		endDate, err = time.Parse("2006-01-02", payload.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format, use YYYY-MM-DD"})
+			return
+		}
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return
+	}
+
+	closure := models.ShopClosure{
+		ID:        uuid.New(),
+		ShopID:    shopID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Reason:    payload.Reason,
+	}
+	if err := h.DB.Create(&closure).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shop closure"})
+		return
+	}
+	c.JSON(http.StatusCreated, closure)
+}
+
+// DeleteShopClosure menghapus hari libur toko
+func (h *Handler) DeleteShopClosure(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+
+	result := h.DB.Where("id = ? AND shop_id = ?", c.Param("closureId"), shopID).Delete(&models.ShopClosure{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete shop closure"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop closure not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
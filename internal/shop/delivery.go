@@ -0,0 +1,157 @@
+// Lokasi: internal/shop/delivery.go
+package shop
+
+import (
+	"net/http"
+
+	"sewascaf.com/api/internal/middleware"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/order"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DeliverySettings struct {
+	DeliveryEnabled bool                  `json:"delivery_enabled"`
+	FeeType         string                `json:"fee_type"`
+	FlatFee         int                   `json:"flat_fee"`
+	Zones           []models.DeliveryZone `json:"zones"`
+}
+
+type UpdateDeliverySettingsPayload struct {
+	DeliveryEnabled *bool  `json:"delivery_enabled" binding:"required"`
+	FeeType         string `json:"fee_type" binding:"required,oneof=flat zone"`
+	FlatFee         int    `json:"flat_fee" binding:"min=0"`
+}
+
+type DeliveryZonePayload struct {
+	Name          string  `json:"name" binding:"required"`
+	MaxDistanceKm float64 `json:"max_distance_km" binding:"required,gt=0"`
+	Fee           int     `json:"fee" binding:"min=0"`
+}
+
+// GetDeliverySettings menampilkan aturan ongkos kirim toko untuk halaman checkout
+func (h *Handler) GetDeliverySettings(c *gin.Context) {
+	var shop models.Shop
+	if err := h.DB.Where("id = ?", c.Param("shopId")).First(&shop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+	settings, err := h.deliverySettings(shop)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve delivery zones"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateDeliverySettings mengatur apakah toko melayani pengiriman dan cara ongkos
+// kirimnya dihitung: tarif tetap (flat) atau per zona jarak (zone)
+func (h *Handler) UpdateDeliverySettings(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+
+	var payload UpdateDeliverySettingsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	var shop models.Shop
+	if err := h.DB.Where("id = ?", shopID).First(&shop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+	if *payload.DeliveryEnabled && payload.FeeType == order.DeliveryFeeZone {
+		var zones int64
+		h.DB.Model(&models.DeliveryZone{}).Where("shop_id = ?", shop.ID).Count(&zones)
+		if zones == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Add at least one delivery zone before using zone fees"})
+			return
+		}
+	}
+
+	shop.DeliveryEnabled = *payload.DeliveryEnabled
+	shop.DeliveryFeeType = payload.FeeType
+	shop.DeliveryFlatFee = payload.FlatFee
+	err := h.DB.Model(&shop).Updates(map[string]interface{}{
+		"delivery_enabled":  shop.DeliveryEnabled,
+		"delivery_fee_type": shop.DeliveryFeeType,
+		"delivery_flat_fee": shop.DeliveryFlatFee,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update delivery settings"})
+		return
+	}
+
+	settings, err := h.deliverySettings(shop)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve delivery zones"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// CreateDeliveryZone menambahkan zona antar berdasarkan jarak dari toko
+func (h *Handler) CreateDeliveryZone(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+
+	var payload DeliveryZonePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	zone := models.DeliveryZone{
+		ID:            uuid.New(),
+		ShopID:        shopID,
+		Name:          payload.Name,
+		MaxDistanceKm: payload.MaxDistanceKm,
+		Fee:           payload.Fee,
+	}
+	if err := h.DB.Create(&zone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create delivery zone"})
+		return
+	}
+	c.JSON(http.StatusCreated, zone)
+}
+
+// DeleteDeliveryZone menghapus zona antar milik toko. Order lama tetap menyimpan
+// ongkos kirim yang sudah dibayar.
+func (h *Handler) DeleteDeliveryZone(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		return
+	}
+
+	result := h.DB.Where("id = ? AND shop_id = ?", c.Param("zoneId"), shopID).Delete(&models.DeliveryZone{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete delivery zone"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery zone not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) deliverySettings(shop models.Shop) (DeliverySettings, error) {
+	settings := DeliverySettings{
+		DeliveryEnabled: shop.DeliveryEnabled,
+		FeeType:         shop.DeliveryFeeType,
+		FlatFee:         shop.DeliveryFlatFee,
+		Zones:           make([]models.DeliveryZone, 0),
+	}
+	err := h.DB.Where("shop_id = ?", shop.ID).Order("max_distance_km ASC").Find(&settings.Zones).Error
+	return settings, err
+}
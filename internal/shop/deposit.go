@@ -0,0 +1,68 @@
+// Lokasi: internal/shop/deposit.go
+package shop
+
+import (
+	"errors"
+	"net/http"
+
+	"sewascaf.com/api/internal/deposit"
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/middleware"
+	"sewascaf.com/api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SettleDepositPayload struct {
+	Deductions []deposit.Deduction `json:"deductions"`
+}
+
+// SettleDeposit menyelesaikan deposit order yang sudah dikembalikan. Tanpa potongan
+// berarti seluruh deposit dikembalikan ke penyewa; setiap potongan wajib punya alasan.
+func (h *Handler) SettleDeposit(c *gin.Context) {
+	vendorID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
+		return
+	}
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": errShopNotFound.Error()})
+		return
+	}
+
+	var payload SettleDepositPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var summary deposit.Summary
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Select("id").Where("id = ? AND shop_id = ?", c.Param("orderId"), shopID).First(&order).Error; err != nil {
+			return errOrderNotOwned
+		}
+
+		var err error
+		summary, err = deposit.Settle(tx, order.ID, payload.Deductions, &vendorID)
+		return err
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, deposit.ErrInvalidDeduction), errors.Is(err, deposit.ErrDeductionTooLarge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, deposit.ErrNoDeposit), errors.Is(err, deposit.ErrAlreadySettled), errors.Is(err, deposit.ErrNotReturned), errors.Is(err, lifecycle.ErrOpenClaim):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, errOrderNotOwned):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle deposit", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
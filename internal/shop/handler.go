@@ -5,39 +5,44 @@ package shop
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"time"
 
+	"sewascaf.com/api/internal/geo"
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/middleware"
 	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/refund"
+	"sewascaf.com/api/internal/tripay"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type Handler struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Tripay   tripay.Client
+	Refunds  refund.Provider
+	Geocoder geo.Geocoder
 }
 
-func NewHandler(db *gorm.DB) *Handler {
-	return &Handler{DB: db}
+func NewHandler(db *gorm.DB, tripayClient tripay.Client, refundProvider refund.Provider, geocoder geo.Geocoder) *Handler {
+	return &Handler{DB: db, Tripay: tripayClient, Refunds: refundProvider, Geocoder: geocoder}
 }
 
 // GetShopPaymentChannels menampilkan metode pembayaran yang sudah dipilih oleh vendor
 func (h *Handler) GetShopPaymentChannels(c *gin.Context) {
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
-		return
-	}
-	userIDString, ok := userIDInterface.(string)
+	shopID, ok := middleware.ShopID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
 		return
 	}
 
 	var shop models.Shop
 	// Kita hanya butuh satu kolom, jadi kita pakai .Select() agar lebih efisien
-	if err := h.DB.Select("active_payment_channels").Where("user_id = ?", userIDString).First(&shop).Error; err != nil {
+	if err := h.DB.Select("active_payment_channels").Where("id = ?", shopID).First(&shop).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
 		return
 	}
@@ -51,21 +56,42 @@ func (h *Handler) GetShopPaymentChannels(c *gin.Context) {
 	c.JSON(http.StatusOK, shop.ActivePaymentChannels)
 }
 
+// GetPublicPaymentChannels menampilkan channel pembayaran yang diaktifkan toko
+// lengkap dengan nama, icon, dan biaya dari Tripay untuk halaman checkout
+func (h *Handler) GetPublicPaymentChannels(c *gin.Context) {
+	shopID := c.Param("shopId")
 
-func (h *Handler) GetShopProfile(c *gin.Context) {
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+	var shop models.Shop
+	if err := h.DB.Select("id", "active_payment_channels").Where("id = ?", shopID).First(&shop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
 		return
 	}
-	userIDString, ok := userIDInterface.(string)
+
+	channels, err := h.Tripay.ListChannels(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get payment channels from Tripay", "details": err.Error()})
+		return
+	}
+
+	enabled := make([]tripay.PaymentChannel, 0)
+	for _, channel := range channels {
+		if channel.Active && shop.ActivePaymentChannels.Contains(channel.Code) {
+			enabled = append(enabled, channel)
+		}
+	}
+	c.JSON(http.StatusOK, enabled)
+}
+
+
+func (h *Handler) GetShopProfile(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop profile not found for this user"})
 		return
 	}
 
 	var shop models.Shop
-	if err := h.DB.Where("user_id = ?", userIDString).First(&shop).Error; err != nil {
+	if err := h.DB.Where("id = ?", shopID).First(&shop).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Shop profile not found for this user"})
 		return
 	}
@@ -74,20 +100,17 @@ func (h *Handler) GetShopProfile(c *gin.Context) {
 }
 
 type UpdateShopPayload struct {
-	ShopName        string `json:"shop_name"`
-	ShopAddress     string `json:"shop_address"`
-	ShopDescription string `json:"shop_description"`
+	ShopName        string   `json:"shop_name"`
+	ShopAddress     string   `json:"shop_address"`
+	ShopDescription string   `json:"shop_description"`
+	Latitude        *float64 `json:"latitude"`
+	Longitude       *float64 `json:"longitude"`
 }
 
 func (h *Handler) UpdateShopProfile(c *gin.Context) {
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
-		return
-	}
-	userIDString, ok := userIDInterface.(string)
+	shopID, ok := middleware.ShopID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop profile not found for this user"})
 		return
 	}
 
@@ -98,7 +121,7 @@ func (h *Handler) UpdateShopProfile(c *gin.Context) {
 	}
 
 	var shop models.Shop
-	if err := h.DB.Where("user_id = ?", userIDString).First(&shop).Error; err != nil {
+	if err := h.DB.Where("id = ?", shopID).First(&shop).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Shop profile not found for this user"})
 		return
 	}
@@ -121,6 +144,21 @@ func (h *Handler) UpdateShopProfile(c *gin.Context) {
 	if payload.ShopAddress != "" {
 		updates["shop_address"] = payload.ShopAddress
 	}
+	// Koordinat toko diperbarui jika dikirim langsung atau jika alamatnya berubah
+	if payload.Latitude != nil || payload.Longitude != nil || (payload.ShopAddress != "" && payload.ShopAddress != shop.ShopAddress) {
+		point, err := geo.Resolve(c.Request.Context(), h.Geocoder, payload.ShopAddress, payload.Latitude, payload.Longitude)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if point != nil {
+			updates["latitude"] = point.Lat
+			updates["longitude"] = point.Lng
+		} else {
+			updates["latitude"] = nil
+			updates["longitude"] = nil
+		}
+	}
 	if payload.ShopDescription != "" {
 		updates["shop_description"] = payload.ShopDescription
 	}
@@ -147,19 +185,8 @@ type TopProductStat struct {
 }
 
 func (h *Handler) GetShopStatistics(c *gin.Context) {
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
-		return
-	}
-	userIDString, ok := userIDInterface.(string)
+	shopID, ok := middleware.ShopID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
-		return
-	}
-
-	var shop models.Shop
-	if err := h.DB.Where("user_id = ?", userIDString).First(&shop).Error; err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "User does not own a shop"})
 		return
 	}
@@ -188,11 +215,11 @@ func (h *Handler) GetShopStatistics(c *gin.Context) {
 	var totalRevenue int
 	var completedOrders int64
 
-	h.DB.Model(&models.Order{}).Where("shop_id = ? AND status = ? AND created_at BETWEEN ? AND ?", shop.ID, "completed", startTime, endTime).Select("COALESCE(SUM(total_price), 0)").Row().Scan(&totalRevenue)
-	h.DB.Model(&models.Order{}).Where("shop_id = ? AND status = ? AND created_at BETWEEN ? AND ?", shop.ID, "completed", startTime, endTime).Count(&completedOrders)
+	h.DB.Model(&models.Order{}).Where("shop_id = ? AND status = ? AND created_at BETWEEN ? AND ?", shopID, "completed", startTime, endTime).Select("COALESCE(SUM(total_price), 0)").Row().Scan(&totalRevenue)
+	h.DB.Model(&models.Order{}).Where("shop_id = ? AND status = ? AND created_at BETWEEN ? AND ?", shopID, "completed", startTime, endTime).Count(&completedOrders)
 
 	var topProducts []TopProductStat
-	h.DB.Table("products").Select(`products.id as product_id, products.name as product_name, COALESCE(SUM(orders.total_price), 0) as revenue, COUNT(orders.id) as rental_count, COALESCE(AVG(reviews.rating), 0) as average_rating`).Joins("LEFT JOIN orders ON orders.shop_id = products.shop_id AND orders.status = 'completed' AND orders.created_at BETWEEN ? AND ?", startTime, endTime).Joins("LEFT JOIN reviews ON reviews.product_id = products.id").Where("products.shop_id = ?", shop.ID).Group("products.id").Order("revenue DESC").Limit(5).Scan(&topProducts)
+	h.DB.Table("products").Select(`products.id as product_id, products.name as product_name, COALESCE(SUM(orders.total_price), 0) as revenue, COUNT(orders.id) as rental_count, COALESCE(AVG(reviews.rating), 0) as average_rating`).Joins("LEFT JOIN orders ON orders.shop_id = products.shop_id AND orders.status = 'completed' AND orders.created_at BETWEEN ? AND ?", startTime, endTime).Joins("LEFT JOIN reviews ON reviews.product_id = products.id").Where("products.shop_id = ?", shopID).Group("products.id").Order("revenue DESC").Limit(5).Scan(&topProducts)
 
 	c.JSON(http.StatusOK, gin.H{
 		"period":           period,
@@ -205,24 +232,13 @@ func (h *Handler) GetShopStatistics(c *gin.Context) {
 }
 
 func (h *Handler) GetShopOrders(c *gin.Context) {
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
-		return
-	}
-	userIDString, ok := userIDInterface.(string)
+	shopID, ok := middleware.ShopID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
-		return
-	}
-
-	var shop models.Shop
-	if err := h.DB.Select("id").Where("user_id = ?", userIDString).First(&shop).Error; err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "User does not own a shop"})
 		return
 	}
 
-	query := h.DB.Model(&models.Order{}).Where("shop_id = ?", shop.ID)
+	query := h.DB.Model(&models.Order{}).Where("shop_id = ?", shopID)
 	statusFilter := c.Query("status")
 	if statusFilter != "" {
 		query = query.Where("status = ?", statusFilter)
@@ -242,17 +258,18 @@ func (h *Handler) GetShopOrders(c *gin.Context) {
 
 type UpdateStatusPayload struct {
 	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
 }
 
 func (h *Handler) UpdateOrderStatus(c *gin.Context) {
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+	vendorID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
 		return
 	}
-	userIDString, ok := userIDInterface.(string)
+	shopID, ok := middleware.ShopID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
+		c.JSON(http.StatusForbidden, gin.H{"error": errShopNotFound.Error()})
 		return
 	}
 	orderID := c.Param("orderId")
@@ -263,34 +280,49 @@ func (h *Handler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	newStatus := payload.Status
-	allowedStatus := map[string]bool{
-		"pending": true, "active": true, "completed": true, "cancelled": true,
-	}
-	if !allowedStatus[newStatus] {
+	if !lifecycle.IsKnown(payload.Status) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status value"})
 		return
 	}
 
-	err := h.DB.Transaction(func(tx *gorm.DB) error {
-		var shop models.Shop
-		if err := tx.Select("id").Where("user_id = ?", userIDString).First(&shop).Error; err != nil {
-			return errors.New("shop not found for this user")
-		}
-
+	var pendingRefund *models.Refund
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
 		var order models.Order
-		if err := tx.Where("id = ? AND shop_id = ?", orderID, shop.ID).First(&order).Error; err != nil {
-			return errors.New("order not found or you do not have permission to edit it")
+		if err := tx.Where("id = ? AND shop_id = ?", orderID, shopID).First(&order).Error; err != nil {
+			return errOrderNotOwned
 		}
 
-		if err := tx.Model(&order).Update("status", newStatus).Error; err != nil {
+		// Order yang sudah dibayar lalu dibatalkan toko dikembalikan penuh ke penyewa
+		paid := order.Status == lifecycle.StatusPaid || order.Status == lifecycle.StatusAwaitingApproval
+		if err := lifecycle.Transition(tx, &order, payload.Status, lifecycle.ActorVendor, &vendorID, payload.Reason); err != nil {
+			return err
+		}
+		if paid && payload.Status == lifecycle.StatusCancelled {
+			var err error
+			pendingRefund, err = refund.Create(tx, order, refund.Full(order), "cancelled by shop", h.Refunds.Name())
 			return err
 		}
 		return nil
 	})
 
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, lifecycle.ErrInvalidTransition), errors.Is(err, lifecycle.ErrStatusChanged), errors.Is(err, lifecycle.ErrOpenClaim):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, refund.ErrNoPaidPayment), errors.Is(err, refund.ErrAlreadyRefunds):
+			c.JSON(http.StatusConflict, gin.H{"error": "Order cannot be cancelled because its refund could not be recorded", "details": err.Error()})
+		case errors.Is(err, errOrderNotOwned), errors.Is(err, lifecycle.ErrActorNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status", "details": err.Error()})
+		}
+		return
+	}
+	if pendingRefund != nil {
+		if err := refund.Execute(c.Request.Context(), h.DB, h.Refunds, pendingRefund); err != nil {
+			log.Printf("Failed to process refund %s for order %s: %v", pendingRefund.ID, pendingRefund.OrderID, err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Order status updated successfully", "refund": pendingRefund})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Order status updated successfully"})
@@ -301,14 +333,9 @@ type UpdatePaymentChannelsPayload struct {
 }
 
 func (h *Handler) UpdatePaymentChannels(c *gin.Context) {
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
-		return
-	}
-	userIDString, ok := userIDInterface.(string)
+	shopID, ok := middleware.ShopID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format in context"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "Shop not found for this user"})
 		return
 	}
 
@@ -319,12 +346,43 @@ func (h *Handler) UpdatePaymentChannels(c *gin.Context) {
 	}
 
 	var shop models.Shop
-	if err := h.DB.Where("user_id = ?", userIDString).First(&shop).Error; err != nil {
+	if err := h.DB.Where("id = ?", shopID).First(&shop).Error; err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Shop not found for this user"})
 		return
 	}
 
-	jsonData, err := json.Marshal(payload.Channels)
+	// Pastikan semua kode channel dikenal dan aktif di Tripay
+	tripayChannels, err := h.Tripay.ListChannels(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get payment channels from Tripay", "details": err.Error()})
+		return
+	}
+	available := make(map[string]bool)
+	for _, channel := range tripayChannels {
+		if channel.Active {
+			available[channel.Code] = true
+		}
+	}
+	channels := make([]string, 0, len(payload.Channels))
+	invalid := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, code := range payload.Channels {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		if !available[code] {
+			invalid = append(invalid, code)
+			continue
+		}
+		channels = append(channels, code)
+	}
+	if len(invalid) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or inactive payment channels", "invalid_channels": invalid})
+		return
+	}
+
+	jsonData, err := json.Marshal(channels)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to marshal payment channels to JSON"})
 		return
@@ -0,0 +1,114 @@
+// Lokasi: internal/shop/latefee.go
+package shop
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/middleware"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/notification"
+	"sewascaf.com/api/internal/tripay"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LateFeePaymentPayload struct {
+	PaymentMethod string `json:"payment_method" binding:"required"`
+}
+
+// IssueLateFeePayment membuat tagihan Tripay tambahan untuk denda keterlambatan
+// order. Tagihan menempel ke order asli dan hanya menagih denda yang belum dibayar.
+func (h *Handler) IssueLateFeePayment(c *gin.Context) {
+	shopID, ok := middleware.ShopID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "shop not found for this user"})
+		return
+	}
+
+	var payload LateFeePaymentPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var shop models.Shop
+	if err := h.DB.Select("id", "active_payment_channels").Where("id = ?", shopID).First(&shop).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "shop not found for this user"})
+		return
+	}
+	if !shop.ActivePaymentChannels.Contains(payload.PaymentMethod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment method is not accepted by this shop"})
+		return
+	}
+
+	var order models.Order
+	if err := h.DB.Where("id = ? AND shop_id = ?", c.Param("orderId"), shop.ID).First(&order).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "order not found or you do not have permission to edit it"})
+		return
+	}
+	switch order.Status {
+	case lifecycle.StatusPickedUp, lifecycle.StatusReturned, lifecycle.StatusCompleted:
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "late fees can only be charged for orders that were picked up"})
+		return
+	}
+	if order.LateFee <= 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "order has no late fee"})
+		return
+	}
+
+	var pending models.Payment
+	if err := h.DB.Where("order_id = ? AND purpose = ? AND status = ?", order.ID, tripay.PurposeLateFee, "UNPAID").First(&pending).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "a late fee payment is still waiting to be paid", "payment": pending})
+		return
+	}
+
+	var paid int
+	h.DB.Model(&models.Payment{}).Select("COALESCE(SUM(amount), 0)").
+		Where("order_id = ? AND purpose = ? AND status = ?", order.ID, tripay.PurposeLateFee, "PAID").
+		Scan(&paid)
+	due := order.LateFee - paid
+	if due <= 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "late fee has already been paid"})
+		return
+	}
+
+	var renter models.User
+	if err := h.DB.First(&renter, "id = ?", order.UserID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load renter"})
+		return
+	}
+
+	transaction, err := h.Tripay.CreateTransaction(c.Request.Context(), tripay.TransactionRequest{
+		Method:        payload.PaymentMethod,
+		MerchantRef:   fmt.Sprintf("%s-LATE-%d", order.ID, time.Now().Unix()),
+		Amount:        due,
+		CustomerName:  renter.Name,
+		CustomerEmail: renter.Email,
+		CustomerPhone: renter.Telepon,
+		OrderItems: []tripay.OrderItem{
+			{SKU: "LATE-FEE", Name: fmt.Sprintf("Late return fee (%d days)", order.LateDays), Price: due, Quantity: 1},
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create Tripay transaction", "details": err.Error()})
+		return
+	}
+
+	payment := tripay.NewPayment(order.ID, transaction)
+	payment.Purpose = tripay.PurposeLateFee
+	if err := h.DB.Create(&payment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save late fee payment"})
+		return
+	}
+	message := fmt.Sprintf("The shop has issued a late fee payment of Rp%d for order %s.", due, order.ID)
+	if err := notification.Notify(h.DB, order.UserID, notification.TypeLateFeeDue, "Late fee payment issued", message, &order.ID); err != nil {
+		log.Printf("Failed to notify renter about late fee for order %s: %v", order.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"payment": payment, "transaction": transaction})
+}
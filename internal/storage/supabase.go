@@ -0,0 +1,52 @@
+// Lokasi: internal/storage/supabase.go
+package storage
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// Supabase mengunggah file ke Supabase Storage memakai service key
+type Supabase struct {
+	URL        string
+	ServiceKey string
+	HTTPClient *http.Client
+}
+
+func NewSupabase(url, serviceKey string) *Supabase {
+	return &Supabase{URL: url, ServiceKey: serviceKey, HTTPClient: &http.Client{}}
+}
+
+// Upload menyimpan file ke bucket dengan nama unik lalu mengembalikan URL publiknya
+func (s *Supabase) Upload(bucket string, file *multipart.FileHeader) (string, error) {
+	fileName := fmt.Sprintf("%s-%s", uuid.New().String(), filepath.Base(file.Filename))
+	uploadURL := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.URL, bucket, fileName)
+
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	req, err := http.NewRequest("POST", uploadURL, src)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.ServiceKey)
+	req.Header.Set("Content-Type", file.Header.Get("Content-Type"))
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("supabase storage returned status %d", resp.StatusCode)
+	}
+
+	return fmt.Sprintf("%s/storage/v1/object/public/%s/%s", s.URL, bucket, fileName), nil
+}
@@ -0,0 +1,182 @@
+// Lokasi: internal/testdb/testdb.go
+
+// Package testdb menyiapkan database Postgres untuk test. Setiap pemanggilan Open
+// membuat schema baru yang dihapus lagi setelah test selesai, sehingga test di
+// package berbeda bisa berjalan paralel di database yang sama.
+package testdb
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"sewascaf.com/api/internal/database"
+	"sewascaf.com/api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open membuka database dari TEST_DATABASE_URL di schema baru yang sudah dimigrasi.
+// Test dilewati jika TEST_DATABASE_URL tidak diatur.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(url), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(url, schema)), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect to test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("migrate test schema: %v", err)
+	}
+	return db
+}
+
+// withSearchPath menambahkan search_path ke DSN berbentuk URL maupun key=value
+func withSearchPath(url, schema string) string {
+	if strings.HasPrefix(url, "postgres://") || strings.HasPrefix(url, "postgresql://") {
+		separator := "?"
+		if strings.Contains(url, "?") {
+			separator = "&"
+		}
+		return url + separator + "search_path=" + schema
+	}
+	return url + " search_path=" + schema
+}
+
+// User membuat user dengan role tertentu
+func User(t testing.TB, db *gorm.DB, role string) models.User {
+	t.Helper()
+	id := uuid.New()
+	user := models.User{
+		ID:       id,
+		Name:     "Test " + role,
+		Email:    id.String() + "@example.com",
+		Password: "unused",
+		Telepon:  "08123456789",
+		Role:     role,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// Shop membuat user pengusaha beserta tokonya
+func Shop(t testing.TB, db *gorm.DB) models.Shop {
+	t.Helper()
+	owner := User(t, db, models.RolePengusaha)
+	shop := models.Shop{
+		ID:                               uuid.New(),
+		UserID:                           owner.ID,
+		ShopName:                         "Test Shop",
+		ShopAddress:                      "Jakarta",
+		CancellationFullRefundDays:       3,
+		CancellationPartialRefundPercent: 50,
+		ApprovalWindowHours:              24,
+		DeliveryFeeType:                  "flat",
+	}
+	if err := db.Create(&shop).Error; err != nil {
+		t.Fatalf("create shop: %v", err)
+	}
+	return shop
+}
+
+// Product membuat produk dengan stok dan harga harian tertentu
+func Product(t testing.TB, db *gorm.DB, shop models.Shop, stock, pricePerDay int) models.Product {
+	t.Helper()
+	id := uuid.New()
+	product := models.Product{
+		ID:          id,
+		ShopID:      shop.ID,
+		SKU:         "SKU-" + id.String()[:8],
+		Name:        "Scaffolding " + id.String()[:4],
+		PricePerDay: pricePerDay,
+		Stock:       stock,
+	}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return product
+}
+
+// Date membuat tanggal UTC tanpa jam, dengan offset hari dari hari ini
+func Date(daysFromToday int) time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, daysFromToday)
+}
+
+// Fatal menghentikan test jika err tidak nil
+func Fatal(t testing.TB, err error, format string, args ...interface{}) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf(format+": %v", append(args, err)...)
+	}
+}
+
+// Order membuat order tanpa item milik renter di toko shop dengan status tertentu
+func Order(t testing.TB, db *gorm.DB, shop models.Shop, renter models.User, status string) models.Order {
+	t.Helper()
+	order := models.Order{
+		ID:            uuid.New(),
+		UserID:        renter.ID,
+		ShopID:        shop.ID,
+		TotalPrice:    300000,
+		Status:        status,
+		StartDate:     Date(7),
+		EndDate:       Date(10),
+		CreatedAt:     time.Now(),
+		PaymentMethod: "BRIVA",
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	return order
+}
+
+// Payment membuat pembayaran utama order dengan status Tripay tertentu
+func Payment(t testing.TB, db *gorm.DB, order models.Order, status string) models.Payment {
+	t.Helper()
+	payment := models.Payment{
+		ID:            uuid.New(),
+		OrderID:       order.ID,
+		Reference:     "T-" + order.ID.String()[:13],
+		MerchantRef:   order.ID.String(),
+		PaymentMethod: order.PaymentMethod,
+		Amount:        order.ChargeAmount(),
+		TotalAmount:   order.ChargeAmount(),
+		Status:        status,
+		Purpose:       "order",
+		CreatedAt:     time.Now(),
+	}
+	if err := db.Create(&payment).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	return payment
+}
@@ -0,0 +1,360 @@
+// Lokasi: internal/tripay/callback.go
+package tripay
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sewascaf.com/api/internal/deposit"
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/notification"
+	"sewascaf.com/api/internal/refund"
+	"sewascaf.com/api/internal/reservation"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CallbackPayload adalah isi callback payment_status dari Tripay
+type CallbackPayload struct {
+	Reference         string `json:"reference"`
+	MerchantRef       string `json:"merchant_ref"`
+	PaymentMethod     string `json:"payment_method"`
+	PaymentMethodCode string `json:"payment_method_code"`
+	TotalAmount       int    `json:"total_amount"`
+	FeeMerchant       int    `json:"fee_merchant"`
+	FeeCustomer       int    `json:"fee_customer"`
+	TotalFee          int    `json:"total_fee"`
+	AmountReceived    int    `json:"amount_received"`
+	IsClosedPayment   int    `json:"is_closed_payment"`
+	Status            string `json:"status"`
+	PaidAt            int64  `json:"paid_at"`
+	Note              string `json:"note"`
+}
+
+// Hasil pemrosesan callback yang disimpan di payment_events
+const (
+	EventProcessed    = "processed"
+	EventDuplicate    = "duplicate"
+	EventIgnored      = "ignored"
+	EventRejected     = "rejected"
+	EventUnknownOrder = "unknown_order"
+)
+
+// processCallback mencatat callback ke payment_events lalu menerapkannya ke order.
+// Callback dengan reference dan status yang sama hanya diproses sekali.
+func (h *Handler) processCallback(payload CallbackPayload, body []byte) (string, error) {
+	var result string
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		event := models.PaymentEvent{
+			ID:             uuid.New(),
+			Reference:      payload.Reference,
+			Status:         payload.Status,
+			MerchantRef:    payload.MerchantRef,
+			TotalAmount:    payload.TotalAmount,
+			AmountReceived: payload.AmountReceived,
+			Payload:        models.JSONRaw(body),
+		}
+		insert := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+		if insert.Error != nil {
+			return insert.Error
+		}
+		if insert.RowsAffected == 0 {
+			result = EventDuplicate
+			return nil
+		}
+
+		var note string
+		var err error
+		result, note, err = applyCallback(tx, payload)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&event).Updates(map[string]interface{}{"result": result, "note": note}).Error
+	})
+	return result, err
+}
+
+func applyCallback(tx *gorm.DB, payload CallbackPayload) (string, string, error) {
+	// Satu reference bisa dipakai beberapa order jika checkout keranjang
+	// digabung menjadi satu transaksi Tripay
+	var payments []models.Payment
+	if err := tx.Where("reference = ?", payload.Reference).Find(&payments).Error; err != nil {
+		return "", "", err
+	}
+
+	if len(payments) > 0 && payments[0].Purpose != "" && payments[0].Purpose != PurposeOrder {
+		return applySupplementary(tx, payload, payments[0])
+	}
+
+	var orderIDs []uuid.UUID
+	for _, payment := range payments {
+		orderIDs = append(orderIDs, payment.OrderID)
+	}
+	if len(orderIDs) == 0 {
+		orderID, err := uuid.Parse(payload.MerchantRef)
+		if err != nil {
+			return EventUnknownOrder, "no order found for merchant_ref " + payload.MerchantRef, nil
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+
+	var orders []models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", orderIDs).Order("id").Find(&orders).Error; err != nil {
+		return "", "", err
+	}
+	if len(orders) == 0 {
+		return EventUnknownOrder, "no order found for merchant_ref " + payload.MerchantRef, nil
+	}
+
+	var target, reason string
+	switch payload.Status {
+	case "PAID":
+		expected := 0
+		for _, order := range orders {
+			expected += order.ChargeAmount()
+		}
+		paidAmount := payload.TotalAmount - payload.FeeCustomer
+		if paidAmount != expected {
+			return EventRejected, fmt.Sprintf("paid amount %d does not match order total %d", paidAmount, expected), nil
+		}
+		target, reason = lifecycle.StatusPaid, "payment received via Tripay"
+	case "EXPIRED":
+		target, reason = lifecycle.StatusExpired, "Tripay payment expired"
+	case "FAILED":
+		target, reason = lifecycle.StatusCancelled, "Tripay payment failed"
+	case "REFUND":
+		target, reason = lifecycle.StatusRefunded, "payment refunded by Tripay"
+	default:
+		return EventIgnored, "unsupported payment status " + payload.Status, nil
+	}
+
+	paymentByOrder := make(map[uuid.UUID]models.Payment, len(payments))
+	for _, payment := range payments {
+		paymentByOrder[payment.OrderID] = payment
+	}
+
+	// Toko dengan mode persetujuan menerima order yang sudah dibayar sebagai awaiting_approval
+	approvalShops := make(map[uuid.UUID]models.Shop)
+	if payload.Status == "PAID" {
+		var shopIDs []uuid.UUID
+		for _, order := range orders {
+			shopIDs = append(shopIDs, order.ShopID)
+		}
+		var shops []models.Shop
+		if err := tx.Where("id IN ? AND requires_approval = ?", shopIDs, true).Find(&shops).Error; err != nil {
+			return "", "", err
+		}
+		for _, shop := range shops {
+			approvalShops[shop.ID] = shop
+		}
+	}
+
+	result := EventIgnored
+	var notes []string
+	for i := range orders {
+		order := &orders[i]
+		// Refund yang dicatat saat pembatalan selesai ketika Tripay mengirim status REFUND
+		if payload.Status == "REFUND" {
+			var pending models.Refund
+			if err := tx.Where("order_id = ? AND status = ?", order.ID, refund.StatusPending).First(&pending).Error; err == nil {
+				if err := refund.Complete(tx, &pending, ""); err != nil {
+					return "", "", err
+				}
+				if err := updatePayment(tx, paymentByOrder[order.ID], payload); err != nil {
+					return "", "", err
+				}
+				result = EventProcessed
+				continue
+			}
+			// Satu reference checkout gabungan: hanya order yang dibatalkan dan punya
+			// refund pending yang ikut selesai, order lain di reference itu tetap jalan
+			if len(orders) > 1 {
+				notes = append(notes, fmt.Sprintf("order %s has no pending refund", order.ID))
+				continue
+			}
+		}
+		orderTarget, orderReason := target, reason
+		shop, needsApproval := approvalShops[order.ShopID]
+		if needsApproval {
+			orderTarget, orderReason = lifecycle.StatusAwaitingApproval, "payment received via Tripay, waiting for shop approval"
+		}
+		if order.Status == orderTarget {
+			notes = append(notes, fmt.Sprintf("order %s is already %s", order.ID, orderTarget))
+			if err := updatePayment(tx, paymentByOrder[order.ID], payload); err != nil {
+				return "", "", err
+			}
+			continue
+		}
+		if err := lifecycle.CanTransition(order.Status, orderTarget, lifecycle.ActorSystem); err != nil {
+			note := fmt.Sprintf("order %s is %s, cannot move to %s", order.ID, order.Status, orderTarget)
+			if payload.Status == "PAID" {
+				note += "; payment received after the order was closed and needs a manual refund"
+			}
+			notes = append(notes, note)
+			continue
+		}
+		if err := lifecycle.Transition(tx, order, orderTarget, lifecycle.ActorSystem, nil, orderReason); err != nil {
+			return "", "", err
+		}
+		if err := updatePayment(tx, paymentByOrder[order.ID], payload); err != nil {
+			return "", "", err
+		}
+		switch orderTarget {
+		case lifecycle.StatusPaid:
+			if err := deposit.Hold(tx, order); err != nil {
+				return "", "", err
+			}
+		case lifecycle.StatusAwaitingApproval:
+			if err := deposit.Hold(tx, order); err != nil {
+				return "", "", err
+			}
+			if err := requestApproval(tx, order, shop); err != nil {
+				return "", "", err
+			}
+		case lifecycle.StatusRefunded:
+			if err := deposit.Release(tx, order.ID, orderReason, nil); err != nil {
+				return "", "", err
+			}
+		}
+		result = EventProcessed
+	}
+	return result, strings.Join(notes, "; "), nil
+}
+
+// paymentCanMove menentukan apakah status pembayaran Tripay boleh berubah dari from ke to.
+// Pembayaran hanya berpindah dari UNPAID; satu-satunya perubahan setelah status akhir
+// adalah PAID menjadi REFUND, sehingga callback yang datang terlambat tidak menimpa pembayaran.
+func paymentCanMove(from, to string) bool {
+	switch from {
+	case "", "UNPAID":
+		return true
+	case "PAID":
+		return to == "REFUND"
+	}
+	return false
+}
+
+// updatePayment menyalin status callback ke pembayaran jika perubahannya diizinkan.
+// Order lama tanpa record pembayaran dilewati.
+func updatePayment(tx *gorm.DB, payment models.Payment, payload CallbackPayload) error {
+	if payment.ID == uuid.Nil || payment.Status == payload.Status || !paymentCanMove(payment.Status, payload.Status) {
+		return nil
+	}
+	updates := map[string]interface{}{"status": payload.Status}
+	if payload.Status == "PAID" && payload.PaidAt > 0 {
+		updates["paid_at"] = time.Unix(payload.PaidAt, 0)
+	}
+	return tx.Model(&models.Payment{}).Where("id = ?", payment.ID).Updates(updates).Error
+}
+
+// requestApproval memberi batas waktu persetujuan ke order dan memberi tahu pemilik toko
+func requestApproval(tx *gorm.DB, order *models.Order, shop models.Shop) error {
+	deadline := time.Now().Add(time.Duration(shop.ApprovalWindowHours) * time.Hour)
+	order.ApprovalDeadline = &deadline
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("approval_deadline", deadline).Error; err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Order %s has been paid and needs your approval before %s. It will be cancelled and refunded automatically after that.",
+		order.ID, deadline.Format("2006-01-02 15:04"))
+	return notification.Notify(tx, shop.UserID, notification.TypeApprovalRequired, "Order waiting for approval", message, &order.ID)
+}
+
+// applySupplementary menerapkan callback untuk tagihan tambahan seperti denda
+// keterlambatan atau perpanjangan sewa. Status order tidak berubah, hanya status pembayarannya.
+func applySupplementary(tx *gorm.DB, payload CallbackPayload, payment models.Payment) (string, string, error) {
+	switch payload.Status {
+	case "PAID":
+		paidAmount := payload.TotalAmount - payload.FeeCustomer
+		if paidAmount != payment.Amount {
+			return EventRejected, fmt.Sprintf("paid amount %d does not match %s amount %d", paidAmount, payment.Purpose, payment.Amount), nil
+		}
+	case "EXPIRED", "FAILED", "REFUND":
+	default:
+		return EventIgnored, "unsupported payment status " + payload.Status, nil
+	}
+	if payment.Status == payload.Status || !paymentCanMove(payment.Status, payload.Status) {
+		return EventIgnored, fmt.Sprintf("%s payment is already %s", payment.Purpose, payment.Status), nil
+	}
+	if err := updatePayment(tx, payment, payload); err != nil {
+		return "", "", err
+	}
+
+	switch payment.Purpose {
+	case PurposeLateFee:
+		if payload.Status == "PAID" {
+			var order models.Order
+			if err := tx.First(&order, "id = ?", payment.OrderID).Error; err != nil {
+				return "", "", err
+			}
+			message := fmt.Sprintf("Late fee of Rp%d for order %s has been paid.", payment.Amount, order.ID)
+			if err := notification.NotifyOrderParties(tx, order, notification.TypeLateFeePaid, "Late fee paid", message); err != nil {
+				return "", "", err
+			}
+		}
+	case PurposeExtension:
+		note, err := applyExtension(tx, payload, payment)
+		if err != nil {
+			return "", "", err
+		}
+		if note != "" {
+			return EventIgnored, note, nil
+		}
+	}
+	return EventProcessed, "", nil
+}
+
+// applyExtension memperpanjang EndDate order setelah pembayaran perpanjangan diterima,
+// atau melepas stok yang ditahan jika pembayarannya gagal atau kedaluwarsa
+func applyExtension(tx *gorm.DB, payload CallbackPayload, payment models.Payment) (string, error) {
+	// merchant_ref transaksi perpanjangan adalah ID perpanjangannya
+	extensionID, err := uuid.Parse(payload.MerchantRef)
+	if err != nil {
+		return "no extension found for merchant_ref " + payload.MerchantRef, nil
+	}
+	var extension models.OrderExtension
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&extension, "id = ?", extensionID).Error; err != nil {
+		return "no extension found for merchant_ref " + payload.MerchantRef, nil
+	}
+	if extension.Status != reservation.ExtensionPending {
+		note := fmt.Sprintf("extension %s is already %s", extension.ID, extension.Status)
+		if payload.Status == "PAID" {
+			note += "; payment received after the extension was closed and needs a manual refund"
+		}
+		return note, nil
+	}
+
+	switch payload.Status {
+	case "PAID":
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND end_date = ? AND status IN ?", extension.OrderID, extension.OldEndDate, []string{lifecycle.StatusPaid, lifecycle.StatusPickedUp}).
+			Update("end_date", extension.NewEndDate)
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if result.RowsAffected == 0 {
+			if err := tx.Model(&extension).Update("status", reservation.ExtensionCancelled).Error; err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("order %s can no longer be extended; payment needs a manual refund", extension.OrderID), nil
+		}
+		now := time.Now()
+		if err := tx.Model(&extension).Updates(map[string]interface{}{"status": reservation.ExtensionConfirmed, "confirmed_at": now}).Error; err != nil {
+			return "", err
+		}
+		var order models.Order
+		if err := tx.First(&order, "id = ?", extension.OrderID).Error; err != nil {
+			return "", err
+		}
+		message := fmt.Sprintf("Order %s has been extended until %s.", order.ID, extension.NewEndDate.Format("2006-01-02"))
+		return "", notification.NotifyOrderParties(tx, order, notification.TypeOrderExtended, "Rental extended", message)
+	case "EXPIRED":
+		return "", tx.Model(&extension).Update("status", reservation.ExtensionExpired).Error
+	case "FAILED":
+		return "", tx.Model(&extension).Update("status", reservation.ExtensionCancelled).Error
+	}
+	return "", nil
+}
@@ -0,0 +1,313 @@
+package tripay
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sewascaf.com/api/internal/lifecycle"
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/refund"
+	"sewascaf.com/api/internal/testdb"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const testPrivateKey = "test-private-key"
+
+// sign menghitung signature seperti yang dikirim Tripay di X-Callback-Signature
+func sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(testPrivateKey))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func postCallback(h *Handler, body []byte, signature, event string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/tripay/callback", h.CallbackHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/tripay/callback", bytes.NewReader(body))
+	req.Header.Set("X-Callback-Signature", signature)
+	if event != "" {
+		req.Header.Set("X-Callback-Event", event)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func callbackBody(t *testing.T, payload CallbackPayload) []byte {
+	t.Helper()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal callback: %v", err)
+	}
+	return body
+}
+
+// TestCallbackHandlerRejectsBadRequests tidak butuh database karena semua kasus
+// ditolak sebelum callback diproses
+func TestCallbackHandlerRejectsBadRequests(t *testing.T) {
+	h := NewHandler(nil, nil, testPrivateKey)
+	valid := callbackBody(t, CallbackPayload{Reference: "T1", MerchantRef: "x", Status: "PAID"})
+	missingStatus := callbackBody(t, CallbackPayload{Reference: "T1"})
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		event     string
+		want      int
+	}{
+		{"missing signature", valid, "", "payment_status", http.StatusForbidden},
+		{"signed with another key", valid, CallbackSignature("other-key", valid), "payment_status", http.StatusForbidden},
+		{"body changed after signing", append([]byte(" "), valid...), sign(valid), "payment_status", http.StatusForbidden},
+		{"unsupported event", valid, sign(valid), "payout_status", http.StatusBadRequest},
+		{"invalid json", []byte("{"), sign([]byte("{")), "payment_status", http.StatusBadRequest},
+		{"missing status", missingStatus, sign(missingStatus), "payment_status", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := postCallback(h, tt.body, tt.signature, tt.event); w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+// callbackFixture membuat order pending beserta pembayaran UNPAID-nya
+func callbackFixture(t *testing.T, db *gorm.DB) (models.Order, models.Payment) {
+	t.Helper()
+	shop := testdb.Shop(t, db)
+	order := testdb.Order(t, db, shop, testdb.User(t, db, models.RoleUser), lifecycle.StatusPending)
+	return order, testdb.Payment(t, db, order, "UNPAID")
+}
+
+func paymentCallback(payment models.Payment, status string) CallbackPayload {
+	return CallbackPayload{
+		Reference:   payment.Reference,
+		MerchantRef: payment.MerchantRef,
+		TotalAmount: payment.Amount,
+		Status:      status,
+	}
+}
+
+func TestCallbackDelivery(t *testing.T) {
+	tests := []struct {
+		name        string
+		deliveries  []string
+		wantStatus  string
+		wantPayment string
+		wantEvents  []string
+	}{
+		{"paid", []string{"PAID"}, lifecycle.StatusPaid, "PAID", []string{EventProcessed}},
+		{"duplicate paid", []string{"PAID", "PAID"}, lifecycle.StatusPaid, "PAID", []string{EventProcessed}},
+		{"expired", []string{"EXPIRED"}, lifecycle.StatusExpired, "EXPIRED", []string{EventProcessed}},
+		{"expired arriving after paid", []string{"PAID", "EXPIRED"}, lifecycle.StatusPaid, "PAID", []string{EventProcessed, EventIgnored}},
+		{"failed arriving after paid", []string{"PAID", "FAILED"}, lifecycle.StatusPaid, "PAID", []string{EventProcessed, EventIgnored}},
+		{"paid arriving after expired", []string{"EXPIRED", "PAID"}, lifecycle.StatusExpired, "EXPIRED", []string{EventProcessed, EventIgnored}},
+		{"failed then duplicate failed", []string{"FAILED", "FAILED"}, lifecycle.StatusCancelled, "FAILED", []string{EventProcessed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.Open(t)
+			order, payment := callbackFixture(t, db)
+			h := NewHandler(db, nil, testPrivateKey)
+
+			for _, status := range tt.deliveries {
+				body := callbackBody(t, paymentCallback(payment, status))
+				if w := postCallback(h, body, sign(body), "payment_status"); w.Code != http.StatusOK {
+					t.Fatalf("%s callback: status = %d (%s)", status, w.Code, w.Body.String())
+				}
+			}
+
+			var got models.Order
+			testdb.Fatal(t, db.First(&got, "id = ?", order.ID).Error, "reload order")
+			if got.Status != tt.wantStatus {
+				t.Errorf("order status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			var gotPayment models.Payment
+			testdb.Fatal(t, db.First(&gotPayment, "id = ?", payment.ID).Error, "reload payment")
+			if gotPayment.Status != tt.wantPayment {
+				t.Errorf("payment status = %s, want %s", gotPayment.Status, tt.wantPayment)
+			}
+			var events []models.PaymentEvent
+			testdb.Fatal(t, db.Where("reference = ?", payment.Reference).Order("created_at").Find(&events).Error, "load events")
+			if len(events) != len(tt.wantEvents) {
+				t.Fatalf("recorded %d events, want %d", len(events), len(tt.wantEvents))
+			}
+			for i, event := range events {
+				if event.Result != tt.wantEvents[i] {
+					t.Errorf("event %d (%s) result = %s, want %s", i, event.Status, event.Result, tt.wantEvents[i])
+				}
+			}
+			var history int64
+			db.Model(&models.OrderStatusHistory{}).Where("order_id = ?", order.ID).Count(&history)
+			if history != 1 {
+				t.Errorf("order has %d status changes, want 1", history)
+			}
+		})
+	}
+}
+
+func TestCallbackRejectsAmountMismatch(t *testing.T) {
+	db := testdb.Open(t)
+	order, payment := callbackFixture(t, db)
+	h := NewHandler(db, nil, testPrivateKey)
+
+	payload := paymentCallback(payment, "PAID")
+	payload.TotalAmount--
+	body := callbackBody(t, payload)
+	if w := postCallback(h, body, sign(body), "payment_status"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", w.Code, w.Body.String())
+	}
+
+	var got models.Order
+	testdb.Fatal(t, db.First(&got, "id = ?", order.ID).Error, "reload order")
+	if got.Status != lifecycle.StatusPending {
+		t.Errorf("order status = %s, want %s", got.Status, lifecycle.StatusPending)
+	}
+	var gotPayment models.Payment
+	testdb.Fatal(t, db.First(&gotPayment, "id = ?", payment.ID).Error, "reload payment")
+	if gotPayment.Status != "UNPAID" {
+		t.Errorf("payment status = %s, want UNPAID", gotPayment.Status)
+	}
+	var event models.PaymentEvent
+	testdb.Fatal(t, db.First(&event, "reference = ?", payment.Reference).Error, "load event")
+	if event.Result != EventRejected {
+		t.Errorf("event result = %s, want %s", event.Result, EventRejected)
+	}
+}
+
+func TestSupplementaryCallbackKeepsTerminalStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		deliveries  []string
+		wantPayment string
+		wantEvents  []string
+	}{
+		{"paid", []string{"PAID"}, "PAID", []string{EventProcessed}},
+		{"expired arriving after paid", []string{"PAID", "EXPIRED"}, "PAID", []string{EventProcessed, EventIgnored}},
+		{"failed arriving after paid", []string{"PAID", "FAILED"}, "PAID", []string{EventProcessed, EventIgnored}},
+		{"paid arriving after expired", []string{"EXPIRED", "PAID"}, "EXPIRED", []string{EventProcessed, EventIgnored}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.Open(t)
+			shop := testdb.Shop(t, db)
+			order := testdb.Order(t, db, shop, testdb.User(t, db, models.RoleUser), lifecycle.StatusReturned)
+			payment := models.Payment{
+				ID:          uuid.New(),
+				OrderID:     order.ID,
+				Reference:   "T-LATE-" + order.ID.String()[:8],
+				MerchantRef: order.ID.String(),
+				Amount:      50000,
+				TotalAmount: 50000,
+				Status:      "UNPAID",
+				Purpose:     PurposeLateFee,
+			}
+			testdb.Fatal(t, db.Create(&payment).Error, "create late fee payment")
+			h := NewHandler(db, nil, testPrivateKey)
+
+			for _, status := range tt.deliveries {
+				body := callbackBody(t, paymentCallback(payment, status))
+				if w := postCallback(h, body, sign(body), "payment_status"); w.Code != http.StatusOK {
+					t.Fatalf("%s callback: status = %d (%s)", status, w.Code, w.Body.String())
+				}
+			}
+
+			var got models.Payment
+			testdb.Fatal(t, db.First(&got, "id = ?", payment.ID).Error, "reload payment")
+			if got.Status != tt.wantPayment {
+				t.Errorf("payment status = %s, want %s", got.Status, tt.wantPayment)
+			}
+			var events []models.PaymentEvent
+			testdb.Fatal(t, db.Where("reference = ?", payment.Reference).Order("created_at").Find(&events).Error, "load events")
+			if len(events) != len(tt.wantEvents) {
+				t.Fatalf("recorded %d events, want %d", len(events), len(tt.wantEvents))
+			}
+			for i, event := range events {
+				if event.Result != tt.wantEvents[i] {
+					t.Errorf("event %d (%s) result = %s, want %s", i, event.Status, event.Result, tt.wantEvents[i])
+				}
+			}
+		})
+	}
+}
+
+// TestRefundCallbackOnCombinedCheckout memakai satu reference untuk dua order:
+// hanya order yang dibatalkan dengan refund pending yang menjadi refunded
+func TestRefundCallbackOnCombinedCheckout(t *testing.T) {
+	db := testdb.Open(t)
+	shop := testdb.Shop(t, db)
+	renter := testdb.User(t, db, models.RoleUser)
+	cancelled := testdb.Order(t, db, shop, renter, lifecycle.StatusCancelled)
+	active := testdb.Order(t, db, shop, renter, lifecycle.StatusPaid)
+
+	checkoutID := uuid.NewString()
+	payments := make([]models.Payment, 2)
+	for i, order := range []models.Order{cancelled, active} {
+		payments[i] = models.Payment{
+			ID:          uuid.New(),
+			OrderID:     order.ID,
+			Reference:   "T-CHECKOUT-" + checkoutID[:8],
+			MerchantRef: checkoutID,
+			Amount:      order.ChargeAmount(),
+			TotalAmount: order.ChargeAmount(),
+			Status:      "PAID",
+			Purpose:     PurposeOrder,
+		}
+		testdb.Fatal(t, db.Create(&payments[i]).Error, "create payment")
+	}
+	pending := models.Refund{
+		ID:               uuid.New(),
+		OrderID:          cancelled.ID,
+		PaymentReference: payments[0].Reference,
+		Rule:             refund.RuleFull,
+		Percent:          100,
+		Amount:           cancelled.ChargeAmount(),
+		Provider:         "manual",
+		Status:           refund.StatusPending,
+	}
+	testdb.Fatal(t, db.Create(&pending).Error, "create refund")
+
+	h := NewHandler(db, nil, testPrivateKey)
+	body := callbackBody(t, paymentCallback(payments[0], "REFUND"))
+	if w := postCallback(h, body, sign(body), "payment_status"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		order       models.Order
+		payment     models.Payment
+		wantStatus  string
+		wantPayment string
+	}{
+		{cancelled, payments[0], lifecycle.StatusRefunded, "REFUND"},
+		{active, payments[1], lifecycle.StatusPaid, "PAID"},
+	}
+	for _, tt := range tests {
+		var got models.Order
+		testdb.Fatal(t, db.First(&got, "id = ?", tt.order.ID).Error, "reload order")
+		if got.Status != tt.wantStatus {
+			t.Errorf("order %s status = %s, want %s", tt.order.ID, got.Status, tt.wantStatus)
+		}
+		var gotPayment models.Payment
+		testdb.Fatal(t, db.First(&gotPayment, "id = ?", tt.payment.ID).Error, "reload payment")
+		if gotPayment.Status != tt.wantPayment {
+			t.Errorf("payment of order %s status = %s, want %s", tt.order.ID, gotPayment.Status, tt.wantPayment)
+		}
+	}
+	testdb.Fatal(t, db.First(&pending, "id = ?", pending.ID).Error, "reload refund")
+	if pending.Status != refund.StatusSucceeded {
+		t.Errorf("refund status = %s, want %s", pending.Status, refund.StatusSucceeded)
+	}
+}
@@ -0,0 +1,245 @@
+// Lokasi: internal/tripay/client.go
+package tripay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SandboxBaseURL    = "https://tripay.co.id/api-sandbox"
+	ProductionBaseURL = "https://tripay.co.id/api"
+)
+
+// BaseURLForMode mengembalikan base URL Tripay untuk mode "sandbox" atau "production"
+func BaseURLForMode(mode string) string {
+	if mode == "production" {
+		return ProductionBaseURL
+	}
+	return SandboxBaseURL
+}
+
+// Client adalah semua operasi Tripay yang dipakai aplikasi
+type Client interface {
+	CreateTransaction(ctx context.Context, req TransactionRequest) (*Transaction, error)
+	GetTransactionDetail(ctx context.Context, reference string) (*Transaction, error)
+	ListChannels(ctx context.Context) ([]PaymentChannel, error)
+	CalculateFee(ctx context.Context, code string, amount int) (*FeeCalculation, error)
+}
+
+// Fee adalah biaya channel dalam bentuk nominal tetap dan persentase
+type Fee struct {
+	Flat    int     `json:"flat"`
+	Percent float64 `json:"percent"`
+}
+
+// Definisikan struct agar sesuai dengan respons JSON dari Tripay
+type PaymentChannel struct {
+	Group       string `json:"group"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	FeeMerchant Fee    `json:"fee_merchant"`
+	FeeCustomer Fee    `json:"fee_customer"`
+	TotalFee    Fee    `json:"total_fee"`
+	MinimumFee  int    `json:"minimum_fee"`
+	MaximumFee  int    `json:"maximum_fee"`
+	Icon        string `json:"icon_url"`
+	Active      bool   `json:"active"`
+}
+
+// FeeCalculation adalah hasil kalkulator biaya Tripay untuk satu channel
+type FeeCalculation struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Fee      Fee    `json:"fee"`
+	TotalFee struct {
+		Merchant int `json:"merchant"`
+		Customer int `json:"customer"`
+	} `json:"total_fee"`
+}
+
+type OrderItem struct {
+	SKU      string `json:"sku"`
+	Name     string `json:"name"`
+	Price    int    `json:"price"`
+	Quantity int    `json:"quantity"`
+}
+
+// TransactionRequest adalah data untuk membuat transaksi closed payment.
+// Signature dihitung otomatis oleh client.
+type TransactionRequest struct {
+	Method        string      `json:"method"`
+	MerchantRef   string      `json:"merchant_ref"`
+	Amount        int         `json:"amount"`
+	CustomerName  string      `json:"customer_name"`
+	CustomerEmail string      `json:"customer_email"`
+	CustomerPhone string      `json:"customer_phone,omitempty"`
+	OrderItems    []OrderItem `json:"order_items"`
+	ExpiredTime   int64       `json:"expired_time,omitempty"`
+}
+
+// Transaction adalah data transaksi yang dikembalikan Tripay
+type Transaction struct {
+	Reference     string          `json:"reference"`
+	MerchantRef   string          `json:"merchant_ref"`
+	PaymentMethod string          `json:"payment_method"`
+	PaymentName   string          `json:"payment_name"`
+	CustomerName  string          `json:"customer_name"`
+	CustomerEmail string          `json:"customer_email"`
+	Amount        int             `json:"amount"`
+	FeeMerchant   int             `json:"fee_merchant"`
+	FeeCustomer   int             `json:"fee_customer"`
+	TotalFee      int             `json:"total_fee"`
+	PayCode       string          `json:"pay_code"`
+	PayURL        string          `json:"pay_url"`
+	CheckoutURL   string          `json:"checkout_url"`
+	QRURL         string          `json:"qr_url"`
+	Status        string          `json:"status"`
+	ExpiredTime   int64           `json:"expired_time"`
+	OrderItems    []OrderItem     `json:"order_items"`
+	Instructions  json.RawMessage `json:"instructions"`
+}
+
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// HTTPClient adalah implementasi Client yang memanggil API Tripay
+type HTTPClient struct {
+	BaseURL      string
+	APIKey       string
+	PrivateKey   string
+	MerchantCode string
+	HTTP         *http.Client
+}
+
+func NewClient(baseURL, apiKey, privateKey, merchantCode string) *HTTPClient {
+	return &HTTPClient{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		APIKey:       apiKey,
+		PrivateKey:   privateKey,
+		MerchantCode: merchantCode,
+		HTTP:         &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// TransactionSignature menghitung signature transaksi closed payment
+func TransactionSignature(privateKey, merchantCode, merchantRef string, amount int) string {
+	mac := hmac.New(sha256.New, []byte(privateKey))
+	mac.Write([]byte(merchantCode + merchantRef + strconv.Itoa(amount)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CallbackSignature menghitung signature body callback Tripay
+func CallbackSignature(privateKey string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(privateKey))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *HTTPClient) CreateTransaction(ctx context.Context, req TransactionRequest) (*Transaction, error) {
+	body, err := json.Marshal(struct {
+		TransactionRequest
+		Signature string `json:"signature"`
+	}{req, TransactionSignature(c.PrivateKey, c.MerchantCode, req.MerchantRef, req.Amount)})
+	if err != nil {
+		return nil, err
+	}
+
+	var transaction Transaction
+	if err := c.do(ctx, http.MethodPost, "/transaction/create", nil, body, &transaction); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (c *HTTPClient) GetTransactionDetail(ctx context.Context, reference string) (*Transaction, error) {
+	var transaction Transaction
+	query := url.Values{"reference": {reference}}
+	if err := c.do(ctx, http.MethodGet, "/transaction/detail", query, nil, &transaction); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (c *HTTPClient) ListChannels(ctx context.Context) ([]PaymentChannel, error) {
+	var channels []PaymentChannel
+	if err := c.do(ctx, http.MethodGet, "/merchant/payment-channel", nil, nil, &channels); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+func (c *HTTPClient) CalculateFee(ctx context.Context, code string, amount int) (*FeeCalculation, error) {
+	var fees []FeeCalculation
+	query := url.Values{"code": {code}, "amount": {strconv.Itoa(amount)}}
+	if err := c.do(ctx, http.MethodGet, "/merchant/fee-calculator", query, nil, &fees); err != nil {
+		return nil, err
+	}
+	if len(fees) == 0 {
+		return nil, errors.New("tripay returned no fee for channel " + code)
+	}
+	return &fees[0], nil
+}
+
+func (c *HTTPClient) do(ctx context.Context, method, path string, query url.Values, body []byte, out interface{}) error {
+	endpoint := c.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = strings.NewReader(string(body))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("tripay request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var apiResp apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("failed to parse response from tripay: %w", err)
+	}
+	if !apiResp.Success {
+		return &APIError{StatusCode: resp.StatusCode, Message: apiResp.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(apiResp.Data, out)
+}
+
+// APIError dikembalikan ketika Tripay membalas dengan success=false
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return "tripay returned an error: " + e.Message
+}
@@ -0,0 +1,229 @@
+// Lokasi: internal/tripay/fake.go
+package tripay
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeServer adalah tiruan API Tripay yang berjalan di dalam proses (httptest).
+// Dipakai untuk pengujian dan development lokal tanpa akses jaringan.
+type FakeServer struct {
+	*httptest.Server
+	APIKey       string
+	PrivateKey   string
+	MerchantCode string
+	Channels     []PaymentChannel
+
+	mu           sync.Mutex
+	transactions map[string]*Transaction
+	sequence     int
+}
+
+// DefaultFakeChannels adalah channel pembayaran bawaan FakeServer
+var DefaultFakeChannels = []PaymentChannel{
+	{Group: "Virtual Account", Code: "BRIVA", Name: "BRI Virtual Account", Type: "direct", FeeCustomer: Fee{Flat: 4250}, TotalFee: Fee{Flat: 4250}, Active: true},
+	{Group: "Virtual Account", Code: "BCAVA", Name: "BCA Virtual Account", Type: "direct", FeeCustomer: Fee{Flat: 5500}, TotalFee: Fee{Flat: 5500}, Active: true},
+	{Group: "E-Wallet", Code: "QRIS", Name: "QRIS", Type: "direct", FeeCustomer: Fee{Flat: 750, Percent: 0.7}, TotalFee: Fee{Flat: 750, Percent: 0.7}, Active: true},
+}
+
+// NewFakeServer menjalankan FakeServer baru. Panggil Close() setelah selesai.
+func NewFakeServer(apiKey, privateKey, merchantCode string) *FakeServer {
+	f := &FakeServer{
+		APIKey:       apiKey,
+		PrivateKey:   privateKey,
+		MerchantCode: merchantCode,
+		Channels:     DefaultFakeChannels,
+		transactions: make(map[string]*Transaction),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/transaction/create", f.handleCreate)
+	mux.HandleFunc("/transaction/detail", f.handleDetail)
+	mux.HandleFunc("/merchant/payment-channel", f.handleChannels)
+	mux.HandleFunc("/merchant/fee-calculator", f.handleFeeCalculator)
+	f.Server = httptest.NewServer(f.authorize(mux))
+	return f
+}
+
+// Client mengembalikan HTTPClient yang mengarah ke FakeServer
+func (f *FakeServer) Client() *HTTPClient {
+	return NewClient(f.URL, f.APIKey, f.PrivateKey, f.MerchantCode)
+}
+
+// Transaction mengambil transaksi yang pernah dibuat berdasarkan reference
+func (f *FakeServer) Transaction(reference string) (Transaction, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	transaction, ok := f.transactions[reference]
+	if !ok {
+		return Transaction{}, false
+	}
+	return *transaction, true
+}
+
+// Callback mengubah status transaksi lalu membuat body callback beserta
+// signature-nya, siap dikirim ke endpoint /tripay/callback.
+func (f *FakeServer) Callback(reference, status string) ([]byte, string, error) {
+	f.mu.Lock()
+	transaction, ok := f.transactions[reference]
+	if ok {
+		transaction.Status = status
+	}
+	f.mu.Unlock()
+	if !ok {
+		return nil, "", fmt.Errorf("fake tripay: transaction %s not found", reference)
+	}
+
+	payload := CallbackPayload{
+		Reference:         transaction.Reference,
+		MerchantRef:       transaction.MerchantRef,
+		PaymentMethod:     transaction.PaymentName,
+		PaymentMethodCode: transaction.PaymentMethod,
+		TotalAmount:       transaction.Amount + transaction.FeeCustomer,
+		FeeMerchant:       transaction.FeeMerchant,
+		FeeCustomer:       transaction.FeeCustomer,
+		TotalFee:          transaction.TotalFee,
+		AmountReceived:    transaction.Amount - transaction.FeeMerchant,
+		IsClosedPayment:   1,
+		Status:            status,
+	}
+	if status == "PAID" {
+		payload.PaidAt = time.Now().Unix()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, CallbackSignature(f.PrivateKey, body), nil
+}
+
+func (f *FakeServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+f.APIKey {
+			writeFake(w, http.StatusUnauthorized, false, "Invalid API Key", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (f *FakeServer) handleCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeFake(w, http.StatusMethodNotAllowed, false, "Method not allowed", nil)
+		return
+	}
+	var req struct {
+		TransactionRequest
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeFake(w, http.StatusBadRequest, false, "Invalid JSON", nil)
+		return
+	}
+	if req.Signature != TransactionSignature(f.PrivateKey, f.MerchantCode, req.MerchantRef, req.Amount) {
+		writeFake(w, http.StatusBadRequest, false, "Invalid signature", nil)
+		return
+	}
+	channel, ok := f.channel(req.Method)
+	if !ok {
+		writeFake(w, http.StatusBadRequest, false, "Payment channel is not enabled", nil)
+		return
+	}
+	itemsTotal := 0
+	for _, item := range req.OrderItems {
+		itemsTotal += item.Price * item.Quantity
+	}
+	if itemsTotal != req.Amount {
+		writeFake(w, http.StatusBadRequest, false, "Amount does not match order items total", nil)
+		return
+	}
+
+	f.mu.Lock()
+	f.sequence++
+	reference := fmt.Sprintf("DEV-T%05d%s", f.sequence, strings.ToUpper(req.Method))
+	expiredTime := req.ExpiredTime
+	if expiredTime == 0 {
+		expiredTime = time.Now().Add(24 * time.Hour).Unix()
+	}
+	feeCustomer := channelFee(channel.FeeCustomer, req.Amount)
+	feeMerchant := channelFee(channel.FeeMerchant, req.Amount)
+	transaction := &Transaction{
+		Reference:     reference,
+		MerchantRef:   req.MerchantRef,
+		PaymentMethod: channel.Code,
+		PaymentName:   channel.Name,
+		CustomerName:  req.CustomerName,
+		CustomerEmail: req.CustomerEmail,
+		Amount:        req.Amount,
+		FeeMerchant:   feeMerchant,
+		FeeCustomer:   feeCustomer,
+		TotalFee:      feeMerchant + feeCustomer,
+		PayCode:       fmt.Sprintf("8888%012d", f.sequence),
+		CheckoutURL:   f.URL + "/checkout/" + reference,
+		Status:        "UNPAID",
+		ExpiredTime:   expiredTime,
+		OrderItems:    req.OrderItems,
+		Instructions:  json.RawMessage(`[{"title":"Fake Tripay","steps":["Use FakeServer.Callback to simulate payment"]}]`),
+	}
+	f.transactions[reference] = transaction
+	f.mu.Unlock()
+
+	writeFake(w, http.StatusOK, true, "", transaction)
+}
+
+func (f *FakeServer) handleDetail(w http.ResponseWriter, r *http.Request) {
+	transaction, ok := f.Transaction(r.URL.Query().Get("reference"))
+	if !ok {
+		writeFake(w, http.StatusNotFound, false, "Transaction not found", nil)
+		return
+	}
+	writeFake(w, http.StatusOK, true, "", transaction)
+}
+
+func (f *FakeServer) handleChannels(w http.ResponseWriter, r *http.Request) {
+	writeFake(w, http.StatusOK, true, "", f.Channels)
+}
+
+func (f *FakeServer) handleFeeCalculator(w http.ResponseWriter, r *http.Request) {
+	channel, ok := f.channel(r.URL.Query().Get("code"))
+	if !ok {
+		writeFake(w, http.StatusBadRequest, false, "Payment channel not found", nil)
+		return
+	}
+	amount, _ := strconv.Atoi(r.URL.Query().Get("amount"))
+	calculation := FeeCalculation{Code: channel.Code, Name: channel.Name, Fee: channel.TotalFee}
+	calculation.TotalFee.Merchant = channelFee(channel.FeeMerchant, amount)
+	calculation.TotalFee.Customer = channelFee(channel.FeeCustomer, amount)
+	writeFake(w, http.StatusOK, true, "", []FeeCalculation{calculation})
+}
+
+func (f *FakeServer) channel(code string) (PaymentChannel, bool) {
+	for _, channel := range f.Channels {
+		if channel.Code == code && channel.Active {
+			return channel, true
+		}
+	}
+	return PaymentChannel{}, false
+}
+
+func channelFee(fee Fee, amount int) int {
+	return fee.Flat + int(math.Ceil(float64(amount)*fee.Percent/100))
+}
+
+func writeFake(w http.ResponseWriter, status int, success bool, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": success,
+		"message": message,
+		"data":    data,
+	})
+}
@@ -0,0 +1,46 @@
+// Lokasi: internal/tripay/payment.go
+package tripay
+
+import (
+	"time"
+
+	"sewascaf.com/api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Tujuan pembayaran. Pembayaran selain PurposeOrder adalah tagihan tambahan
+// yang menempel ke order yang sudah dibayar dan tidak mengubah status order.
+const (
+	PurposeOrder     = "order"
+	PurposeLateFee   = "late_fee"
+	PurposeExtension = "extension"
+)
+
+// NewPayment menyalin data transaksi Tripay ke record Payment milik sebuah order
+func NewPayment(orderID uuid.UUID, transaction *Transaction) models.Payment {
+	payment := models.Payment{
+		ID:            uuid.New(),
+		OrderID:       orderID,
+		Reference:     transaction.Reference,
+		MerchantRef:   transaction.MerchantRef,
+		PaymentMethod: transaction.PaymentMethod,
+		PaymentName:   transaction.PaymentName,
+		Amount:        transaction.Amount,
+		FeeMerchant:   transaction.FeeMerchant,
+		FeeCustomer:   transaction.FeeCustomer,
+		TotalAmount:   transaction.Amount + transaction.FeeCustomer,
+		PayCode:       transaction.PayCode,
+		PayURL:        transaction.PayURL,
+		CheckoutURL:   transaction.CheckoutURL,
+		QRURL:         transaction.QRURL,
+		Instructions:  models.JSONRaw(transaction.Instructions),
+		Status:        transaction.Status,
+		Purpose:       PurposeOrder,
+	}
+	if transaction.ExpiredTime > 0 {
+		expiredAt := time.Unix(transaction.ExpiredTime, 0)
+		payment.ExpiredAt = &expiredAt
+	}
+	return payment
+}
@@ -0,0 +1,105 @@
+package twofactor
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret adalah kunci SHA-1 dari RFC 6238 Appendix B ("12345678901234567890") dalam base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeRFC6238 memakai test vector SHA-1 RFC 6238. RFC memakai 8 digit,
+// jadi kode 6 digit adalah 6 digit terakhirnya.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseSecret(t *testing.T) {
+	got, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", Step(time.Unix(59, 0)))
+	if err != nil || got != "287082" {
+		t.Errorf("Code(lowercase) = %s, %v; want 287082, nil", got, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	code := func(step int64) string {
+		c, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatalf("Code(%d): %v", step, err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(current), 0, current, true},
+		{"one step behind", code(current - 1), 0, current - 1, true},
+		{"one step ahead", code(current + 1), 0, current + 1, true},
+		{"two steps behind", code(current - 2), 0, 0, false},
+		{"two steps ahead", code(current + 2), 0, 0, false},
+		{"surrounding spaces", " " + code(current) + " ", 0, current, true},
+		{"wrong length", code(current)[:5], 0, 0, false},
+		{"wrong code", "000000", 0, 0, false},
+		{"already used step", code(current), current, 0, false},
+		{"step before the last used step", code(current - 1), current, 0, false},
+		{"next step after the last used step", code(current + 1), current, current + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate(%q, last %d) = %d, %v; want %d, %v", tt.code, tt.lastStep, step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestValidateRejectsReplay memakai kode yang sama dua kali di langkah yang sama
+func TestValidateRejectsReplay(t *testing.T) {
+	now := time.Unix(2000000000, 0)
+	code, err := Code(rfcSecret, Step(now))
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	step, ok := Validate(rfcSecret, code, now, 0)
+	if !ok {
+		t.Fatalf("first use rejected")
+	}
+	if _, ok := Validate(rfcSecret, code, now.Add(10*time.Second), step); ok {
+		t.Errorf("replayed code accepted")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	got := ProvisioningURI("SewaScaf", "vendor@example.com", rfcSecret)
+	want := "otpauth://totp/SewaScaf:vendor@example.com?algorithm=SHA1&digits=6&issuer=SewaScaf&period=30&secret=" + rfcSecret
+	if got != want {
+		t.Errorf("ProvisioningURI = %s, want %s", got, want)
+	}
+}
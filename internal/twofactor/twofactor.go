@@ -0,0 +1,130 @@
+// Lokasi: internal/twofactor/twofactor.go
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Issuer adalah nama yang tampil di aplikasi authenticator
+const Issuer = "SewaScaf"
+
+// RecoveryCodeCount adalah jumlah kode cadangan yang dibuat setiap kali enrollment
+const RecoveryCodeCount = 10
+
+var (
+	ErrNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrInvalidCode = errors.New("invalid two-factor code")
+)
+
+// Enabled mengecek apakah user sudah menyelesaikan enrollment 2FA
+func Enabled(user models.User) bool {
+	return user.TwoFactorEnabledAt != nil && user.TwoFactorSecret != ""
+}
+
+// Verify mencocokkan code dengan TOTP user atau, jika gagal, dengan salah satu
+// kode cadangan yang belum terpakai. Baris user dikunci di transaksi tx agar
+// kode yang sama tidak bisa dipakai oleh dua request sekaligus.
+// Mengembalikan true jika yang dipakai adalah kode cadangan.
+func Verify(tx *gorm.DB, userID uuid.UUID, code string, now time.Time) (bool, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+		return false, err
+	}
+	if !Enabled(user) {
+		return false, ErrNotEnabled
+	}
+
+	if step, ok := Validate(user.TwoFactorSecret, code, now, user.TwoFactorLastStep); ok {
+		return false, tx.Model(&user).Update("two_factor_last_step", step).Error
+	}
+
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, ErrInvalidCode
+	}
+	return true, nil
+}
+
+// ReplaceRecoveryCodes menghapus kode cadangan lama user lalu membuat yang baru.
+// Kode asli hanya dikembalikan sekali di sini.
+func ReplaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, RecoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{ID: uuid.New(), UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RemainingRecoveryCodes menghitung kode cadangan user yang belum terpakai
+func RemainingRecoveryCodes(db *gorm.DB, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// LoadSettings membaca pengaturan keamanan platform; nilai default jika belum pernah diatur
+func LoadSettings(db *gorm.DB) (models.SecuritySettings, error) {
+	var settings models.SecuritySettings
+	err := db.Where("id = ?", 1).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.SecuritySettings{ID: 1}, nil
+	}
+	return settings, err
+}
+
+// Required mengecek apakah user dengan role tersebut wajib memakai 2FA
+func Required(db *gorm.DB, role string) (bool, error) {
+	if role != models.RolePengusaha {
+		return false, nil
+	}
+	settings, err := LoadSettings(db)
+	if err != nil {
+		return false, err
+	}
+	return settings.RequireVendorTwoFactor, nil
+}
+
+// newRecoveryCode membuat kode 10 karakter dengan format xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(secretEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode menormalkan kode (huruf kecil, tanpa tanda hubung dan spasi) lalu meng-hash-nya
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,115 @@
+package twofactor
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/testdb"
+
+	"gorm.io/gorm"
+)
+
+func TestNewRecoveryCodeFormat(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			t.Fatalf("newRecoveryCode: %v", err)
+		}
+		if !format.MatchString(code) {
+			t.Errorf("recovery code %q does not match xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("recovery code %q generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+// Kode cadangan boleh diketik dengan huruf besar, tanpa tanda hubung, atau dengan spasi
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	want := hashRecoveryCode("abcde-fghij")
+	for _, code := range []string{"ABCDE-FGHIJ", "abcdefghij", "abcde fghij"} {
+		if got := hashRecoveryCode(code); got != want {
+			t.Errorf("hashRecoveryCode(%q) differs from the canonical form", code)
+		}
+	}
+	if hashRecoveryCode("abcde-fghik") == want {
+		t.Errorf("different codes share a hash")
+	}
+}
+
+// enrolledUser membuat user dengan 2FA aktif memakai secret RFC 6238
+func enrolledUser(t *testing.T, db *gorm.DB) models.User {
+	t.Helper()
+	user := testdb.User(t, db, models.RolePengusaha)
+	now := time.Now()
+	err := db.Model(&user).Updates(map[string]interface{}{"two_factor_secret": rfcSecret, "two_factor_enabled_at": now}).Error
+	testdb.Fatal(t, err, "enable two-factor")
+	return user
+}
+
+func verify(db *gorm.DB, user models.User, code string, now time.Time) (bool, error) {
+	var usedRecovery bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		usedRecovery, err = Verify(tx, user.ID, code, now)
+		return err
+	})
+	return usedRecovery, err
+}
+
+func TestVerifyRecoveryCodeIsSingleUse(t *testing.T) {
+	db := testdb.Open(t)
+	user := enrolledUser(t, db)
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = ReplaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	testdb.Fatal(t, err, "ReplaceRecoveryCodes")
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), RecoveryCodeCount)
+	}
+
+	var stored models.RecoveryCode
+	testdb.Fatal(t, db.First(&stored, "user_id = ?", user.ID).Error, "load recovery code")
+	for _, code := range codes {
+		if stored.CodeHash == code {
+			t.Fatalf("recovery code stored in plain text")
+		}
+	}
+
+	now := time.Now()
+	usedRecovery, err := verify(db, user, codes[0], now)
+	if err != nil || !usedRecovery {
+		t.Fatalf("first use = %v, %v; want true, nil", usedRecovery, err)
+	}
+	if _, err := verify(db, user, codes[0], now); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("reused recovery code error = %v, want %v", err, ErrInvalidCode)
+	}
+	if remaining, err := RemainingRecoveryCodes(db, user.ID); err != nil || remaining != RecoveryCodeCount-1 {
+		t.Errorf("remaining recovery codes = %d, %v; want %d", remaining, err, RecoveryCodeCount-1)
+	}
+}
+
+func TestVerifyRejectsReplayedTOTP(t *testing.T) {
+	db := testdb.Open(t)
+	user := enrolledUser(t, db)
+
+	now := time.Now()
+	code, err := Code(rfcSecret, Step(now))
+	testdb.Fatal(t, err, "Code")
+	if usedRecovery, err := verify(db, user, code, now); err != nil || usedRecovery {
+		t.Fatalf("first use = %v, %v; want false, nil", usedRecovery, err)
+	}
+	if _, err := verify(db, user, code, now); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("replayed code error = %v, want %v", err, ErrInvalidCode)
+	}
+}
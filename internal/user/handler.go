@@ -7,12 +7,13 @@ import (
 	"log"
 	"net/http"
 	"path/filepath"
-	"time" // REVISI: Import baru untuk JWT
+	"strconv"
 
+	"sewascaf.com/api/internal/geo"
 	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/session"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5" // REVISI: Import baru untuk JWT
 	"github.com/google/uuid"
 
 	"gorm.io/gorm"
@@ -23,16 +24,18 @@ type Handler struct {
 	DB                 *gorm.DB
 	SupabaseURL        string
 	SupabaseServiceKey string
-	JWTSecret          string // REVISI: Tambahkan JWTSecret untuk membuat token baru
+	Sessions           *session.Issuer // Untuk membuat token baru setelah upgrade
+	Geocoder           geo.Geocoder
 }
 
 // NewHandler adalah constructor untuk membuat instance Handler baru
-func NewHandler(db *gorm.DB, supabaseURL string, supabaseServiceKey string, jwtSecret string) *Handler { // REVISI: Tambahkan parameter jwtSecret
+func NewHandler(db *gorm.DB, supabaseURL string, supabaseServiceKey string, sessions *session.Issuer, geocoder geo.Geocoder) *Handler {
 	return &Handler{
 		DB:                 db,
 		SupabaseURL:        supabaseURL,
 		SupabaseServiceKey: supabaseServiceKey,
-		JWTSecret:          jwtSecret, // REVISI: Inisialisasi JWTSecret
+		Sessions:           sessions,
+		Geocoder:           geocoder,
 	}
 }
 
@@ -53,6 +56,54 @@ func (h *Handler) GetProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+type UpdateAddressPayload struct {
+	Alamat    string   `json:"alamat" binding:"required"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// UpdateAddress mengubah alamat user beserta koordinatnya. Jika koordinat tidak
+// dikirim, alamat di-geocode; alamat yang tidak dikenal disimpan tanpa koordinat.
+func (h *Handler) UpdateAddress(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	var payload UpdateAddressPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	point, err := geo.Resolve(c.Request.Context(), h.Geocoder, payload.Alamat, payload.Latitude, payload.Longitude)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if result := h.DB.Where("id = ?", userID).First(&user); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	user.Alamat = payload.Alamat
+	user.Latitude, user.Longitude = nil, nil
+	if point != nil {
+		user.Latitude, user.Longitude = &point.Lat, &point.Lng
+	}
+	err = h.DB.Model(&user).Updates(map[string]interface{}{
+		"alamat":    user.Alamat,
+		"latitude":  user.Latitude,
+		"longitude": user.Longitude,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update address"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
 // UpgradeToVendor mengubah role user menjadi 'pengusaha' dan membuat profil toko
 func (h *Handler) UpgradeToVendor(c *gin.Context) {
 	userID, exists := c.Get("userID")
@@ -71,6 +122,11 @@ func (h *Handler) UpgradeToVendor(c *gin.Context) {
 	shopAddress := c.PostForm("shop_address")
 	shopPhoneNumber := c.PostForm("shop_phone_number")
 	shopDescription := c.PostForm("shop_description")
+	shopLocation, err := geo.Resolve(c.Request.Context(), h.Geocoder, shopAddress, formFloat(c, "shop_latitude"), formFloat(c, "shop_longitude"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	
 	src, err := file.Open()
 	if err != nil {
@@ -118,11 +174,11 @@ func (h *Handler) UpgradeToVendor(c *gin.Context) {
 			return errors.New("user not found")
 		}
 
-		if user.Role != "user" {
+		if user.Role != models.RoleUser {
 			return errors.New("user is already a vendor or has a different role")
 		}
 
-		if err := tx.Model(&user).Update("role", "pengusaha").Error; err != nil {
+		if err := tx.Model(&user).Update("role", models.RolePengusaha).Error; err != nil {
 			return err
 		}
 
@@ -135,6 +191,9 @@ func (h *Handler) UpgradeToVendor(c *gin.Context) {
 			ShopDescription:     shopDescription,
 			ShopProfileImageURL: imageURL,
 		}
+		if shopLocation != nil {
+			newShop.Latitude, newShop.Longitude = &shopLocation.Lat, &shopLocation.Lng
+		}
 		if err := tx.Create(&newShop).Error; err != nil {
 			return err
 		}
@@ -147,13 +206,10 @@ func (h *Handler) UpgradeToVendor(c *gin.Context) {
 		return
 	}
 
-	// REVISI: Buat token JWT baru setelah role berhasil diubah
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	newTokenString, err := token.SignedString([]byte(h.JWTSecret))
+	// Buat access token baru untuk sesi yang sedang dipakai setelah role berhasil diubah
+	sessionID, _ := c.Get("sessionID")
+	sid, _ := sessionID.(uuid.UUID)
+	newTokenString, err := h.Sessions.AccessToken(user, sid)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token after role upgrade"})
 		return
@@ -164,4 +220,13 @@ func (h *Handler) UpgradeToVendor(c *gin.Context) {
 		"message":   "Successfully upgraded to vendor. Please use the new token.",
 		"new_token": newTokenString,
 	})
-}
\ No newline at end of file
+}
+
+// formFloat membaca field form angka desimal opsional; kosong atau tidak valid berarti nil
+func formFloat(c *gin.Context, key string) *float64 {
+	value, err := strconv.ParseFloat(c.PostForm(key), 64)
+	if err != nil {
+		return nil
+	}
+	return &value
+}
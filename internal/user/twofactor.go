@@ -0,0 +1,256 @@
+// Lokasi: internal/user/twofactor.go
+package user
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"sewascaf.com/api/internal/models"
+	"sewascaf.com/api/internal/twofactor"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorPayload struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// GetTwoFactorStatus menampilkan status 2FA user dan apakah 2FA wajib untuk role-nya
+func (h *Handler) GetTwoFactorStatus(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := h.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	required, err := twofactor.Required(h.DB, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor policy"})
+		return
+	}
+	remaining, err := twofactor.RemainingRecoveryCodes(h.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  twofactor.Enabled(user),
+		"enabled_at":               user.TwoFactorEnabledAt,
+		"required":                 required,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// SetupTwoFactor membuat secret TOTP baru yang belum aktif. Client menampilkan
+// provisioning URI sebagai QR code, lalu mengonfirmasi lewat EnableTwoFactor.
+func (h *Handler) SetupTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := h.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if twofactor.Enabled(user) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := twofactor.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate two-factor secret"})
+		return
+	}
+	err = h.DB.Model(&user).Updates(map[string]interface{}{"two_factor_secret": secret, "two_factor_last_step": 0}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save two-factor secret"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": twofactor.ProvisioningURI(twofactor.Issuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor mengaktifkan 2FA setelah user membuktikan authenticator-nya
+// menghasilkan kode yang benar. Kode cadangan hanya ditampilkan sekali di sini.
+// Sesi lain dicabut karena belum pernah melewati 2FA.
+func (h *Handler) EnableTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var payload TwoFactorCodePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var user models.User
+	var recoveryCodes []string
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+		if twofactor.Enabled(user) {
+			return errTwoFactorEnabled
+		}
+		if user.TwoFactorSecret == "" {
+			return errTwoFactorNotSetUp
+		}
+		now := time.Now()
+		step, ok := twofactor.Validate(user.TwoFactorSecret, payload.Code, now, user.TwoFactorLastStep)
+		if !ok {
+			return twofactor.ErrInvalidCode
+		}
+		user.TwoFactorEnabledAt = &now
+		user.TwoFactorLastStep = step
+		err := tx.Model(&user).Updates(map[string]interface{}{"two_factor_enabled_at": now, "two_factor_last_step": step}).Error
+		if err != nil {
+			return err
+		}
+		recoveryCodes, err = twofactor.ReplaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	sessionID, _ := c.Get("sessionID")
+	sid, _ := sessionID.(uuid.UUID)
+	if _, err := h.Sessions.RevokeOthers(user.ID.String(), sid); err != nil {
+		log.Printf("Failed to revoke other sessions of user %s after enabling 2FA: %v", user.ID, err)
+	}
+	// Sesi ini baru saja membuktikan kode 2FA, jadi token barunya membawa status 2FA
+	// dan fitur vendor yang mewajibkan 2FA langsung terbuka
+	if err := h.Sessions.MarkTwoFactor(sid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
+		return
+	}
+	newToken, err := h.Sessions.AccessToken(user, sid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled. Store the recovery codes somewhere safe.",
+		"recovery_codes": recoveryCodes,
+		"new_token":      newToken,
+	})
+}
+
+// DisableTwoFactor mematikan 2FA dengan konfirmasi password dan kode 2FA.
+// Tidak bisa dilakukan jika admin mewajibkan 2FA untuk role user.
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var payload DisableTwoFactorPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	required, err := twofactor.Required(h.DB, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor policy"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your account and cannot be disabled"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := twofactor.Verify(tx, user.ID, payload.Code, time.Now()); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_secret":     "",
+			"two_factor_enabled_at": nil,
+			"two_factor_last_step":  0,
+		}).Error
+	})
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	user.TwoFactorSecret, user.TwoFactorEnabledAt = "", nil
+	sessionID, _ := c.Get("sessionID")
+	sid, _ := sessionID.(uuid.UUID)
+	newToken, err := h.Sessions.AccessToken(user, sid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled", "new_token": newToken})
+}
+
+// RegenerateRecoveryCodes mengganti semua kode cadangan setelah kode 2FA dikonfirmasi
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	var payload TwoFactorCodePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	id, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+		return
+	}
+
+	var recoveryCodes []string
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := twofactor.Verify(tx, id, payload.Code, time.Now()); err != nil {
+			return err
+		}
+		recoveryCodes, err = twofactor.ReplaceRecoveryCodes(tx, id)
+		return err
+	})
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+var (
+	errTwoFactorEnabled  = errors.New("two-factor authentication is already enabled")
+	errTwoFactorNotSetUp = errors.New("two-factor setup has not been started")
+)
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, twofactor.ErrInvalidCode), errors.Is(err, twofactor.ErrNotEnabled), errors.Is(err, errTwoFactorNotSetUp):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errTwoFactorEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update two-factor settings"})
+	}
+}